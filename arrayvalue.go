@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// elementField returns the field description of the elements of an array
+// column, or false for columns that aren't arrays pgx knows about.
+func elementField(field pgconn.FieldDescription) (pgconn.FieldDescription, bool) {
+	t, ok := typeMap.TypeForOID(field.DataTypeOID)
+	if !ok {
+		return field, false
+	}
+	codec, ok := t.Codec.(*pgtype.ArrayCodec)
+	if !ok {
+		return field, false
+	}
+	field.DataTypeOID = codec.ElementType.OID
+	return field, true
+}
+
+// rawArrayValues decodes multidimensional arrays again from the data sent
+// by the server. pgx flattens them into a single slice.
+func rawArrayValues(fields []pgconn.FieldDescription, values []any, raw [][]byte) error {
+	for i, f := range fields {
+		if _, ok := elementField(f); !ok || values[i] == nil {
+			continue
+		}
+		var arr pgtype.Array[any]
+		if err := typeMap.PlanScan(f.DataTypeOID, f.Format, &arr).Scan(raw[i], &arr); err != nil {
+			return err
+		}
+		if len(arr.Dims) > 1 {
+			values[i] = nestArray(arr.Elements, arr.Dims)
+		}
+	}
+	return nil
+}
+
+func nestArray(elems []any, dims []pgtype.ArrayDimension) []any {
+	if len(dims) == 1 {
+		return elems
+	}
+	n := int(dims[0].Length)
+	size := len(elems) / n
+	nested := make([]any, n)
+	for i := range nested {
+		nested[i] = nestArray(elems[i*size:(i+1)*size], dims[1:])
+	}
+	return nested
+}
+
+// arrayText renders an array as Postgres array literal like {a,b,NULL}, or
+// as JSON with --array-format json.
+func (f *valueFormat) arrayText(field pgconn.FieldDescription, arr []any) string {
+	if f.arrayFormat == "json" {
+		b, err := json.Marshal(f.json(field, arr))
+		if err == nil {
+			return string(b)
+		}
+	}
+	elem, _ := elementField(field)
+	var s strings.Builder
+	f.writeArrayLiteral(&s, elem, arr)
+	return s.String()
+}
+
+func (f *valueFormat) writeArrayLiteral(s *strings.Builder, elem pgconn.FieldDescription, arr []any) {
+	s.WriteByte('{')
+	for i, v := range arr {
+		if i > 0 {
+			s.WriteByte(',')
+		}
+		switch val := v.(type) {
+		case nil:
+			s.WriteString("NULL")
+		case []any:
+			f.writeArrayLiteral(s, elem, val)
+		default:
+			s.WriteString(quoteArrayElement(f.text(elem, v)))
+		}
+	}
+	s.WriteByte('}')
+}
+
+// quoteArrayElement quotes elements the way Postgres does in array output.
+func quoteArrayElement(s string) string {
+	if s != "" && !strings.EqualFold(s, "NULL") && !strings.ContainsAny(s, "{}\",\\ \t\n\r\v\f") {
+		return s
+	}
+	return `"` + arrayElementEscaper.Replace(s) + `"`
+}
+
+var arrayElementEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
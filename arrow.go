@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const arrowBatchSize = 1024
+
+// arrowWriter emits the result set as an Arrow IPC stream, flushing a
+// record batch every arrowBatchSize rows.
+type arrowWriter struct {
+	out     io.Writer
+	values  *valueFormat
+	fields  []pgconn.FieldDescription
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	w       *ipc.Writer
+	rows    int
+}
+
+func (w *arrowWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	arrowFields := make([]arrow.Field, len(fields))
+	for i, f := range fields {
+		arrowFields[i] = arrow.Field{
+			Name:     f.Name,
+			Type:     arrowType(f),
+			Nullable: true,
+			Metadata: arrow.NewMetadata([]string{"pg_type"}, []string{typeName(f.DataTypeOID)}),
+		}
+	}
+	w.schema = arrow.NewSchema(arrowFields, nil)
+	w.builder = array.NewRecordBuilder(memory.DefaultAllocator, w.schema)
+	w.w = ipc.NewWriter(w.out, ipc.WithSchema(w.schema))
+	return nil
+}
+
+// arrowType maps a Postgres column to the closest Arrow type. Types without
+// a natural counterpart are transported as their text representation.
+func arrowType(field pgconn.FieldDescription) arrow.DataType {
+	switch field.DataTypeOID {
+	case pgtype.BoolOID:
+		return arrow.FixedWidthTypes.Boolean
+	case pgtype.Int2OID:
+		return arrow.PrimitiveTypes.Int16
+	case pgtype.Int4OID:
+		return arrow.PrimitiveTypes.Int32
+	case pgtype.Int8OID:
+		return arrow.PrimitiveTypes.Int64
+	case pgtype.Float4OID:
+		return arrow.PrimitiveTypes.Float32
+	case pgtype.Float8OID:
+		return arrow.PrimitiveTypes.Float64
+	case pgtype.NumericOID:
+		if prec, scale, ok := numericTypmod(field.TypeModifier); ok && prec <= 38 {
+			return &arrow.Decimal128Type{Precision: prec, Scale: scale}
+		}
+	case pgtype.ByteaOID:
+		return arrow.BinaryTypes.Binary
+	case pgtype.DateOID:
+		return arrow.FixedWidthTypes.Date32
+	case pgtype.TimestampOID:
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case pgtype.TimestamptzOID:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case pgtype.TimeOID:
+		return arrow.FixedWidthTypes.Time64us
+	}
+	return arrow.BinaryTypes.String
+}
+
+// numericTypmod decodes precision and scale of a numeric(p, s) column.
+func numericTypmod(typmod int32) (int32, int32, bool) {
+	if typmod < 4 {
+		return 0, 0, false
+	}
+	typmod -= 4
+	return (typmod >> 16) & 0xffff, typmod & 0xffff, true
+}
+
+func (w *arrowWriter) WriteRow(values []any) error {
+	for i, v := range values {
+		if err := w.appendValue(w.builder.Field(i), w.fields[i], v); err != nil {
+			return fmt.Errorf("column %q: %w", w.fields[i].Name, err)
+		}
+	}
+	w.rows++
+	if w.rows%arrowBatchSize == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+// appendValue adds a single value to its column builder. Infinite dates and
+// timestamps have no Arrow representation and are written as null.
+func (w *arrowWriter) appendValue(b array.Builder, field pgconn.FieldDescription, v any) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	if _, ok := v.(pgtype.InfinityModifier); ok {
+		b.AppendNull()
+		return nil
+	}
+
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		return appendAs(b.Append, v)
+	case *array.Int16Builder:
+		return appendAs(b.Append, v)
+	case *array.Int32Builder:
+		return appendAs(b.Append, v)
+	case *array.Int64Builder:
+		return appendAs(b.Append, v)
+	case *array.Float32Builder:
+		return appendAs(b.Append, v)
+	case *array.Float64Builder:
+		return appendAs(b.Append, v)
+	case *array.BinaryBuilder:
+		return appendAs(b.Append, v)
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		num, ok := v.(pgtype.Numeric)
+		if !ok {
+			return fmt.Errorf("unexpected value of type %T", v)
+		}
+		if num.NaN || num.InfinityModifier != pgtype.Finite {
+			b.AppendNull()
+			return nil
+		}
+		n, err := decimal128.FromString(exactNumeric.format(num), dt.Precision, dt.Scale)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *array.Date32Builder:
+		return appendAs(func(t time.Time) { b.Append(arrow.Date32FromTime(t)) }, v)
+	case *array.TimestampBuilder:
+		return appendAs(func(t time.Time) { b.Append(arrow.Timestamp(t.UnixMicro())) }, v)
+	case *array.Time64Builder:
+		return appendAs(func(t pgtype.Time) { b.Append(arrow.Time64(t.Microseconds)) }, v)
+	case *array.StringBuilder:
+		b.Append(w.values.text(field, v))
+	default:
+		return fmt.Errorf("unsupported arrow builder %T", b)
+	}
+	return nil
+}
+
+func appendAs[T any](appendFn func(T), v any) error {
+	val, ok := v.(T)
+	if !ok {
+		return fmt.Errorf("unexpected value of type %T", v)
+	}
+	appendFn(val)
+	return nil
+}
+
+func (w *arrowWriter) flush() error {
+	rec := w.builder.NewRecord()
+	defer rec.Release()
+	return w.w.Write(rec)
+}
+
+func (w *arrowWriter) Close() error {
+	defer w.builder.Release()
+	if w.rows%arrowBatchSize != 0 || w.rows == 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	return w.w.Close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name of the stored profile passwords.
+const keyringService = "pgexec"
+
+func authCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "auth",
+		Usage: "Store profile passwords in the OS keychain",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "login",
+				Usage:     "Prompt for the password of a profile and store it in the keychain",
+				UsageText: "pgexec auth login prod-replica",
+				Action: func(cCtx *cli.Context) error {
+					return authLogin(cCtx.Args().Get(0))
+				},
+			},
+			{
+				Name:      "logout",
+				Usage:     "Remove the password of a profile from the keychain",
+				UsageText: "pgexec auth logout prod-replica",
+				Action: func(cCtx *cli.Context) error {
+					return authLogout(cCtx.Args().Get(0))
+				},
+			},
+		},
+	}
+}
+
+// authLogin stores the password of a profile in the macOS Keychain, the
+// Secret Service on Linux or the Windows Credential Manager.
+func authLogin(name string) error {
+	if name == "" {
+		return errors.New("no profile given")
+	}
+	if _, err := loadProfile(name); err != nil {
+		return err
+	}
+	password, err := readSecret(fmt.Sprintf("Password for profile %s: ", name))
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(keyringService, name, password); err != nil {
+		return fmt.Errorf("store password: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Password for profile %s stored in the keychain\n", name)
+	return nil
+}
+
+// authLogout removes the stored password of a profile.
+func authLogout(name string) error {
+	if name == "" {
+		return errors.New("no profile given")
+	}
+	err := keyring.Delete(keyringService, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("no password stored for profile %s", name)
+	}
+	return err
+}
+
+// keyringPassword returns the stored password of a profile. A missing
+// keychain, like on a server without Secret Service, is treated as no
+// password.
+func keyringPassword(name string) (string, bool) {
+	password, err := keyring.Get(keyringService, name)
+	return password, err == nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// avroWriter writes the result set into an Avro object container file. The
+// schema is derived from the result field descriptions, every field being
+// a nullable union.
+type avroWriter struct {
+	out    io.Writer
+	values *valueFormat
+	fields []pgconn.FieldDescription
+	names  []string
+	enc    *ocf.Encoder
+}
+
+func (w *avroWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	w.names = avroNames(fields)
+
+	schemaFields := make([]map[string]any, len(fields))
+	for i, f := range fields {
+		schemaFields[i] = map[string]any{
+			"name":    w.names[i],
+			"type":    []any{"null", avroType(f)},
+			"default": nil,
+		}
+	}
+	schema, err := json.Marshal(map[string]any{
+		"type":      "record",
+		"name":      "Row",
+		"namespace": "pgexec",
+		"fields":    schemaFields,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.enc, err = ocf.NewEncoder(string(schema), w.out)
+	return err
+}
+
+var avroInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// avroNames turns column names into unique, valid Avro field names.
+func avroNames(fields []pgconn.FieldDescription) []string {
+	names := make([]string, len(fields))
+	seen := map[string]bool{}
+	for i, f := range fields {
+		name := avroInvalidChars.ReplaceAllString(f.Name, "_")
+		if name == "" || (name[0] >= '0' && name[0] <= '9') {
+			name = "_" + name
+		}
+		unique := name
+		for n := 2; seen[unique]; n++ {
+			unique = name + "_" + strconv.Itoa(n)
+		}
+		seen[unique] = true
+		names[i] = unique
+	}
+	return names
+}
+
+func avroType(field pgconn.FieldDescription) any {
+	switch field.DataTypeOID {
+	case pgtype.BoolOID:
+		return "boolean"
+	case pgtype.Int2OID, pgtype.Int4OID:
+		return "int"
+	case pgtype.Int8OID:
+		return "long"
+	case pgtype.Float4OID:
+		return "float"
+	case pgtype.Float8OID:
+		return "double"
+	case pgtype.ByteaOID:
+		return "bytes"
+	case pgtype.NumericOID:
+		if prec, scale, ok := numericTypmod(field.TypeModifier); ok {
+			return map[string]any{"type": "bytes", "logicalType": "decimal", "precision": prec, "scale": scale}
+		}
+	case pgtype.DateOID:
+		return map[string]any{"type": "int", "logicalType": "date"}
+	case pgtype.TimestampOID, pgtype.TimestamptzOID:
+		return map[string]any{"type": "long", "logicalType": "timestamp-micros"}
+	case pgtype.TimeOID:
+		return map[string]any{"type": "long", "logicalType": "time-micros"}
+	case pgtype.UUIDOID:
+		return map[string]any{"type": "string", "logicalType": "uuid"}
+	}
+	return "string"
+}
+
+func (w *avroWriter) WriteRow(values []any) error {
+	record := make(map[string]any, len(values))
+	for i, v := range values {
+		val, err := w.avroValue(w.fields[i], v)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", w.fields[i].Name, err)
+		}
+		record[w.names[i]] = val
+	}
+	return w.enc.Encode(record)
+}
+
+// avroValue converts a scanned value to the Go type hamba/avro expects for
+// the schema generated by avroType. Values without an Avro representation,
+// like infinite timestamps, are written as null.
+func (w *avroWriter) avroValue(field pgconn.FieldDescription, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch val := v.(type) {
+	case pgtype.InfinityModifier:
+		return nil, nil
+	case int16:
+		return int32(val), nil
+	case bool, int32, int64, float32, float64, []byte, time.Time:
+		return val, nil
+	case pgtype.Time:
+		return time.Duration(val.Microseconds) * time.Microsecond, nil
+	case pgtype.Numeric:
+		if _, ok := avroType(field).(map[string]any); !ok {
+			return exactNumeric.format(val), nil
+		}
+		if val.NaN || val.InfinityModifier != pgtype.Finite {
+			return nil, nil
+		}
+		rat, ok := new(big.Rat).SetString(exactNumeric.format(val))
+		if !ok {
+			return nil, fmt.Errorf("invalid numeric %v", v)
+		}
+		return rat, nil
+	}
+	return w.values.text(field, v), nil
+}
+
+func (w *avroWriter) Close() error {
+	return w.enc.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// awsIAMAuth authenticates with RDS IAM auth tokens generated from the
+// ambient AWS credentials. Tokens expire after 15 minutes, so one is
+// generated for every new connection.
+func awsIAMAuth(ctx context.Context, config *pgxpool.Config, connArgs connArgs) error {
+	switch {
+	case connArgs.azureADAuth:
+		return errors.New("pass either --aws-iam-auth or --azure-ad-auth, not both")
+	case connArgs.promptPassword:
+		return errors.New("--prompt-password can't be combined with IAM authentication")
+	}
+	if err := requireTLS(config, "--aws-iam-auth"); err != nil {
+		return err
+	}
+	// The token is signed for one endpoint, and pgx uses the same password
+	// for every host.
+	for _, fb := range config.ConnConfig.Fallbacks {
+		if fb.Host != config.ConnConfig.Host || fb.Port != config.ConnConfig.Port {
+			return errors.New("--aws-iam-auth can't be combined with multiple hosts, the token is only valid for one")
+		}
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	region := connArgs.awsRegion
+	if region == "" {
+		region = awsCfg.Region
+	}
+	if region == "" {
+		region = rdsRegion(config.ConnConfig.Host)
+	}
+	if region == "" {
+		return errors.New("--aws-iam-auth needs a region, pass --aws-region or set AWS_REGION")
+	}
+	config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		endpoint := net.JoinHostPort(cc.Host, strconv.Itoa(int(cc.Port)))
+		token, err := auth.BuildAuthToken(ctx, endpoint, region, cc.User, awsCfg.Credentials)
+		if err != nil {
+			return err
+		}
+		cc.Password = token
+		return nil
+	}
+	return nil
+}
+
+// rdsRegion returns the region of an RDS endpoint like
+// db.abc123.eu-central-1.rds.amazonaws.com.
+func rdsRegion(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 5 && strings.HasSuffix(host, ".rds.amazonaws.com") {
+		return parts[len(parts)-4]
+	}
+	return ""
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// azureDatabaseScope is the scope of access tokens for Azure Database for
+// PostgreSQL.
+const azureDatabaseScope = "https://ossrdbms-aad.database.windows.net/.default"
+
+// azureADAuth authenticates with Entra ID access tokens from the default
+// Azure credential chain. The credential caches the token and refreshes it
+// before it expires, so each new connection gets a valid one.
+func azureADAuth(config *pgxpool.Config, connArgs connArgs) error {
+	if connArgs.promptPassword {
+		return errors.New("--prompt-password can't be combined with --azure-ad-auth")
+	}
+	if err := requireTLS(config, "--azure-ad-auth"); err != nil {
+		return err
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return err
+	}
+	config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{azureDatabaseScope}})
+		if err != nil {
+			return err
+		}
+		cc.Password = token.Token
+		return nil
+	}
+	return nil
+}
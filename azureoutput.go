@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// azureUpload uploads to a blob in a container of the storage account in
+// $AZURE_STORAGE_ACCOUNT with the default Azure credential chain, or with
+// $AZURE_STORAGE_CONNECTION_STRING. The output is staged in blocks as it is
+// produced and only committed at the end.
+func azureUpload(container, blob string) (func(context.Context, io.Reader) error, error) {
+	client, err := azureBlobClient()
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r io.Reader) error {
+		_, err := client.UploadStream(ctx, container, blob, r, nil)
+		return err
+	}, nil
+}
+
+func azureBlobClient() (*azblob.Client, error) {
+	if connStr := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); connStr != "" {
+		return azblob.NewClientFromConnectionString(connStr, nil)
+	}
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return nil, errors.New("set AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_CONNECTION_STRING for az:// outputs")
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClient("https://"+account+".blob.core.windows.net/", cred, nil)
+}
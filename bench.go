@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchmark runs the statements repeat times, after warmup runs that are
+// not measured, and prints latency statistics for each statement instead
+// of their results. Every run is rolled back unless --no-transaction is
+// given, so statements that write can be measured repeatedly as well.
+func benchmark(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, out io.Writer, statements []sqlStatement) error {
+	if connArgs.warmup < 0 {
+		return fmt.Errorf("invalid --warmup %d", connArgs.warmup)
+	}
+	latencies := make([][]time.Duration, len(statements))
+	rowCounts := make([]int64, len(statements))
+	for run := 0; run < connArgs.warmup+connArgs.repeat; run++ {
+		durations, rows, err := benchmarkRun(ctx, pool, connArgs, statements)
+		if err != nil {
+			return err
+		}
+		if run < connArgs.warmup {
+			continue
+		}
+		for i, d := range durations {
+			latencies[i] = append(latencies[i], d)
+			rowCounts[i] = rows[i]
+		}
+	}
+
+	for i, stmt := range statements {
+		fmt.Fprintf(out, "%s\n  runs=%d rows=%d  %s\n",
+			statementSummary(stmt.sql), len(latencies[i]), rowCounts[i], latencySummary(latencies[i]))
+	}
+	return nil
+}
+
+// latencySummary returns the min, mean, p95 and max of latencies.
+func latencySummary(latencies []time.Duration) string {
+	d := slices.Clone(latencies)
+	slices.Sort(d)
+	var sum time.Duration
+	for _, l := range d {
+		sum += l
+	}
+	// p95 by nearest rank.
+	p95 := d[int(math.Ceil(0.95*float64(len(d))))-1]
+	return fmt.Sprintf("min=%s  mean=%s  p95=%s  max=%s",
+		millis(d[0]), millis(sum/time.Duration(len(d))), millis(p95), millis(d[len(d)-1]))
+}
+
+// benchmarkRun executes the statements once and returns the latency and
+// the number of rows of each of them.
+func benchmarkRun(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, statements []sqlStatement) ([]time.Duration, []int64, error) {
+	var ex Executor = pool
+	if !connArgs.noTx {
+		tx, err := pool.BeginTx(ctx, connArgs.txOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer tx.Rollback(ctx)
+		ex = tx
+	}
+	durations := make([]time.Duration, len(statements))
+	rowCounts := make([]int64, len(statements))
+	for i, stmt := range statements {
+		start := time.Now()
+		rows, err := ex.Query(ctx, stmt.sql, stmt.args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			rowCounts[i]++
+		}
+		rows.Close()
+		durations[i] = time.Since(start)
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		if !rows.CommandTag().Select() {
+			rowCounts[i] = rows.CommandTag().RowsAffected()
+		}
+	}
+	return durations, rowCounts, nil
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
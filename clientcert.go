@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/youmark/pkcs8"
+)
+
+// clientCertFiles returns the client certificate and key from the flags
+// or PGSSLCERT and PGSSLKEY.
+func clientCertFiles(connArgs connArgs) (cert, key string) {
+	cert, key = trim(connArgs.sslCert), trim(connArgs.sslKey)
+	if cert == "" {
+		cert = os.Getenv("PGSSLCERT")
+	}
+	if key == "" {
+		key = os.Getenv("PGSSLKEY")
+	}
+	return cert, key
+}
+
+// loadClientCert loads a client certificate and its private key. Unlike
+// pgx, which only decrypts legacy PEM encryption of RSA keys, legacy
+// encrypted EC keys and encrypted PKCS#8 keys of any type are supported.
+// Without --sslpassword or PGSSLPASSWORD the passphrase of an encrypted
+// key is prompted for.
+func loadClientCert(connArgs connArgs, certFile, keyFile string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to read sslcert: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("unable to read sslkey: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("failed to decode sslkey %s", keyFile)
+	}
+	// Legacy PEM encryption is deprecated, but still what openssl rsa -des3
+	// writes.
+	legacy := x509.IsEncryptedPEMBlock(block)
+	if !legacy && block.Type != "ENCRYPTED PRIVATE KEY" {
+		return tls.X509KeyPair(certPEM, keyPEM)
+	}
+
+	password := connArgs.sslPassword
+	if password == "" {
+		password = os.Getenv("PGSSLPASSWORD")
+	}
+	if password == "" {
+		if password, err = readSecret(fmt.Sprintf("Enter PEM pass phrase for %s: ", keyFile)); err != nil {
+			return tls.Certificate{}, fmt.Errorf("sslkey %s is encrypted, pass --sslpassword: %w", keyFile, err)
+		}
+	}
+	var der []byte
+	if legacy {
+		plain, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to decrypt sslkey: %w", err)
+		}
+		var key any
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(plain)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(plain)
+		default:
+			return tls.Certificate{}, fmt.Errorf("sslkey %s: unsupported encrypted %s", keyFile, block.Type)
+		}
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+	} else {
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("unable to decrypt sslkey: %w", err)
+		}
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// setClientCert presents cert on every connection attempt of the config
+// that uses TLS.
+func setClientCert(config *pgxpool.Config, cert tls.Certificate) error {
+	cc := config.ConnConfig
+	configs := []*tls.Config{cc.TLSConfig}
+	for _, fb := range cc.Fallbacks {
+		configs = append(configs, fb.TLSConfig)
+	}
+	found := false
+	for _, c := range configs {
+		if c != nil {
+			c.Certificates = []tls.Certificate{cert}
+			found = true
+		}
+	}
+	if !found {
+		return errors.New("a client certificate needs TLS, check --sslmode")
+	}
+	return nil
+}
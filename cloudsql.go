@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cloudSQL dials the --cloudsql-instance through the Cloud SQL connector,
+// which sets up TLS with the instance certificate itself, so pgx must not
+// negotiate TLS again. Without a password, also none from
+// --password-command or --prompt-password which are resolved later, IAM
+// database authentication is used with the ambient Google credentials. The
+// dialer lives as long as the process.
+func cloudSQL(ctx context.Context, config *pgxpool.Config, connArgs connArgs) error {
+	if connArgs.ssh != "" {
+		return errors.New("pass either --cloudsql-instance or --ssh, not both")
+	}
+	cc := config.ConnConfig
+	var opts []cloudsqlconn.Option
+	if cc.Password == "" && connArgs.passwordCommand == "" && !connArgs.promptPassword {
+		opts = append(opts, cloudsqlconn.WithIAMAuthN())
+	}
+	if connArgs.cloudSQLPrivateIP {
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPrivateIP()))
+	}
+	if connArgs.keepalive > 0 {
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithTCPKeepAlive(connArgs.keepalive)))
+	}
+	dialer, err := cloudsqlconn.NewDialer(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	instance := connArgs.cloudSQLInstance
+	cc.TLSConfig = nil
+	cc.Fallbacks = nil
+	cc.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+	cc.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.Dial(ctx, instance)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var sqlKeywords = strings.Fields(`
+	ALL ALTER ANALYZE AND ANY ARRAY AS ASC BEGIN BETWEEN BIGINT BOOLEAN BY
+	CASCADE CASE CAST CHECK COLUMN COMMIT CONCURRENTLY CONFLICT CONSTRAINT
+	COPY CREATE CROSS CURRENT_DATE CURRENT_TIMESTAMP DATABASE DEFAULT DELETE
+	DESC DISTINCT DO DROP ELSE END EXCEPT EXISTS EXPLAIN EXTENSION FALSE
+	FETCH FILTER FIRST FOREIGN FROM FULL FUNCTION GRANT GROUP HAVING ILIKE
+	IN INDEX INNER INSERT INTEGER INTERSECT INTERVAL INTO IS JOIN JSONB KEY
+	LATERAL LEFT LIKE LIMIT MATERIALIZED NOT NOTHING NULL NULLS OFFSET ON
+	OR ORDER OUTER OVER PARTITION PRIMARY REFERENCES REFRESH RENAME
+	REPLACE RETURNING REVOKE RIGHT ROLE ROLLBACK SCHEMA SELECT SEQUENCE SET
+	SHOW TABLE TEMPORARY TEXT THEN TIMESTAMPTZ TO TRIGGER TRUE TRUNCATE
+	UNION UNIQUE UPDATE USING VACUUM VALUES VIEW WHEN WHERE WINDOW WITH
+`)
+
+// catalogNamesQuery lists the schemas, tables, views, columns and
+// functions users refer to, without the system catalogs.
+const catalogNamesQuery = `
+WITH rels AS (
+	SELECT c.oid, c.relname, n.nspname
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f')
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg_toast%'
+)
+SELECT relname FROM rels
+UNION SELECT nspname || '.' || relname FROM rels
+UNION SELECT a.attname FROM pg_catalog.pg_attribute a JOIN rels ON rels.oid = a.attrelid
+	WHERE a.attnum > 0 AND NOT a.attisdropped
+UNION SELECT nspname FROM pg_catalog.pg_namespace
+	WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+UNION SELECT proname FROM pg_catalog.pg_proc p JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+	WHERE n.nspname <> 'information_schema'`
+
+// completer completes SQL keywords and the names in the catalog of the
+// database. The names are fetched on the first completion and cached until
+// refresh is called.
+type completer struct {
+	pool *pgxpool.Pool
+
+	mu     sync.Mutex
+	names  []string
+	loaded bool
+}
+
+// refresh drops the cached names, e.g. after DDL, so they are fetched
+// again on the next completion.
+func (c *completer) refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names, c.loaded = nil, false
+}
+
+func (c *completer) catalogNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		// Completion must not hang the prompt, names are simply missing if
+		// the catalog can't be queried.
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		rows, err := c.pool.Query(ctx, catalogNamesQuery)
+		if err == nil {
+			c.names, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		}
+		c.loaded = err == nil
+	}
+	return c.names
+}
+
+// Do implements readline.AutoCompleter for the word in front of the
+// cursor. Keywords are completed in the case the word is typed in.
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+	start := pos
+	for start > 0 && isCompletionRune(line[start-1]) {
+		start--
+	}
+	word := string(line[start:pos])
+	if word == "" {
+		return nil, 0
+	}
+	lower := strings.ToLower(word)
+
+	var candidates []string
+	for _, kw := range sqlKeywords {
+		if strings.HasPrefix(strings.ToLower(kw), lower) {
+			if word == lower {
+				kw = strings.ToLower(kw)
+			}
+			candidates = append(candidates, kw)
+		}
+	}
+	for _, name := range c.catalogNames() {
+		if strings.HasPrefix(strings.ToLower(name), lower) {
+			candidates = append(candidates, name)
+		}
+	}
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+
+	n := len([]rune(word))
+	suffixes := make([][]rune, len(candidates))
+	for i, cand := range candidates {
+		suffixes[i] = []rune(cand)[n:]
+	}
+	return suffixes, n
+}
+
+func isCompletionRune(r rune) bool {
+	return r == '_' || r == '.' || r == '$' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jackc/pgservicefile"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolConfig returns the pool configuration for --url, or for the single
+// connection flags. Parameters that aren't given are taken from the PG*
+// environment variables by pgx, like libpq does.
+func poolConfig(ctx context.Context, connArgs connArgs) (*pgxpool.Config, error) {
+	socket, err := daemonSocket(connArgs)
+	if err != nil {
+		return nil, err
+	}
+	if socket != "" {
+		return daemonPoolConfig(connArgs, socket)
+	}
+	connStr, err := normalizeConnString(connArgs.url)
+	if err != nil {
+		return nil, err
+	}
+	if connStr != "" && connArgs.service != "" {
+		return nil, errors.New("pass either --url or --service, not both")
+	}
+	params := connParams(connArgs)
+	var cert *tls.Certificate
+	if certFile, keyFile := clientCertFiles(connArgs); certFile != "" && keyFile != "" {
+		c, err := loadClientCert(connArgs, certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cert = &c
+		// The certificate is set on the config below, keep pgx from
+		// loading it again from the environment.
+		params = append(params, connParam{"sslcert", ""}, connParam{"sslkey", ""})
+	}
+	connStr, err = withConnParams(connStr, params)
+	if err != nil {
+		return nil, err
+	}
+	if connArgs.password == "" && os.Getenv("PGPASSWORD") == "" {
+		checkPassfile()
+	}
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if connArgs.keepalive > 0 {
+		// Replaces the dialer of pgx, which probes every 5 minutes.
+		dialer := &net.Dialer{KeepAlive: connArgs.keepalive}
+		config.ConnConfig.DialFunc = dialer.DialContext
+	}
+	if err := gssEncMode(config, connArgs); err != nil {
+		return nil, err
+	}
+	if err := channelBinding(config, connArgs); err != nil {
+		return nil, err
+	}
+	// Identifies pgexec in pg_stat_activity and the server log.
+	if config.ConnConfig.RuntimeParams["application_name"] == "" {
+		config.ConnConfig.RuntimeParams["application_name"] = "pgexec"
+	}
+	if connArgs.connectTimeout > 0 {
+		config.ConnConfig.ConnectTimeout = connArgs.connectTimeout
+	}
+	if connArgs.tlsSkipVerify {
+		if err := skipTLSVerify(config); err != nil {
+			return nil, err
+		}
+	}
+	if cert != nil {
+		if err := setClientCert(config, *cert); err != nil {
+			return nil, err
+		}
+	}
+	if connArgs.ssh != "" {
+		if err := sshTunnel(config, connArgs); err != nil {
+			return nil, err
+		}
+	}
+	if connArgs.cloudSQLInstance != "" {
+		if err := cloudSQL(ctx, config, connArgs); err != nil {
+			return nil, err
+		}
+	}
+	if connArgs.awsIAMAuth {
+		if err := awsIAMAuth(ctx, config, connArgs); err != nil {
+			return nil, err
+		}
+	}
+	if connArgs.azureADAuth {
+		if err := azureADAuth(config, connArgs); err != nil {
+			return nil, err
+		}
+	}
+	if connArgs.passwordCommand != "" {
+		if err := passwordCommand(config, connArgs); err != nil {
+			return nil, err
+		}
+	}
+	if trim(connArgs.url) == "" {
+		config.MaxConns = 10
+	}
+	return config, nil
+}
+
+// explicitConnFlag returns the first flag that is set which names the
+// database to connect to or how to authenticate, or "".
+func explicitConnFlag(connArgs connArgs) string {
+	flags := []struct {
+		name string
+		set  bool
+	}{
+		{"url", connArgs.url != "" || len(connArgs.urls) > 0},
+		{"host", connArgs.host != ""},
+		{"port", connArgs.port != ""},
+		{"user", connArgs.user != ""},
+		{"password", connArgs.password != ""},
+		{"db", connArgs.database != ""},
+		{"service", connArgs.service != ""},
+		{"socket", connArgs.socket != ""},
+		{"target-session-attrs", connArgs.targetSessionAttrs != ""},
+		{"sslmode", connArgs.sslMode != ""},
+		{"sslrootcert", connArgs.sslRootCert != ""},
+		{"sslcert", connArgs.sslCert != ""},
+		{"sslkey", connArgs.sslKey != ""},
+		{"sslpassword", connArgs.sslPassword != ""},
+		{"tls-skip-verify", connArgs.tlsSkipVerify},
+		{"channel-binding", connArgs.channelBinding != ""},
+		{"krbsrvname", connArgs.krbSrvName != ""},
+		{"krbspn", connArgs.krbSPN != ""},
+		{"gssencmode", connArgs.gssEncMode != ""},
+		{"prompt-password", connArgs.promptPassword},
+		{"password-command", connArgs.passwordCommand != ""},
+		{"ssh", connArgs.ssh != ""},
+		{"aws-iam-auth", connArgs.awsIAMAuth},
+		{"azure-ad-auth", connArgs.azureADAuth},
+		{"cloudsql-instance", connArgs.cloudSQLInstance != ""},
+	}
+	for _, f := range flags {
+		if f.set {
+			return f.name
+		}
+	}
+	return ""
+}
+
+// requireTLS refuses connections without TLS, for authentication methods
+// that send tokens as password. Plaintext fallbacks of sslmode=prefer are
+// dropped.
+func requireTLS(config *pgxpool.Config, flag string) error {
+	cc := config.ConnConfig
+	if cc.TLSConfig == nil {
+		return fmt.Errorf("%s requires TLS, use --sslmode require or verify-full", flag)
+	}
+	fallbacks := cc.Fallbacks[:0]
+	for _, fb := range cc.Fallbacks {
+		if fb.TLSConfig != nil {
+			fallbacks = append(fallbacks, fb)
+		}
+	}
+	cc.Fallbacks = fallbacks
+	return nil
+}
+
+// channelBinding checks --channel-binding, or channel_binding from the
+// connection string or $PGCHANNELBINDING. pgx authenticates with
+// SCRAM-SHA-256 but doesn't implement SCRAM-SHA-256-PLUS, so require can't
+// be met and prefer is the same as disable, like libpq without TLS.
+func channelBinding(config *pgxpool.Config, connArgs connArgs) error {
+	params := config.ConnConfig.RuntimeParams
+	mode := params["channel_binding"]
+	// Not a server setting, it would be rejected as unknown on connect.
+	delete(params, "channel_binding")
+	if connArgs.channelBinding != "" {
+		mode = connArgs.channelBinding
+	}
+	if mode == "" {
+		mode = os.Getenv("PGCHANNELBINDING")
+	}
+	switch mode {
+	case "", "disable", "prefer":
+		return nil
+	case "require":
+		return errors.New("channel_binding require is not supported, SCRAM-SHA-256-PLUS isn't implemented; use --sslmode verify-full to authenticate the server")
+	default:
+		return fmt.Errorf("unknown channel_binding %q", mode)
+	}
+}
+
+// checkPassfile warns about a password file that others can read, as
+// libpq does. pgx looks up passwords in $PGPASSFILE or ~/.pgpass when none
+// is given.
+func checkPassfile() {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	path := os.Getenv("PGPASSFILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "warning: password file %q has group or world access; permissions should be u=rw (0600) or less\n", path)
+	}
+}
+
+// connParam is a connection parameter given as a flag.
+type connParam struct {
+	key, value string
+}
+
+// connParams returns the connection parameters set with flags. With --url
+// or an alias they override the parts of the URL, like the database of
+// pgexec prod --db tenant_42.
+func connParams(connArgs connArgs) []connParam {
+	host, port := connArgs.host, connArgs.port
+	if connArgs.socket != "" {
+		host, port = socketHost(connArgs.socket, port)
+	}
+	all := []connParam{
+		{"host", host},
+		{"port", port},
+		{"user", connArgs.user},
+		{"password", connArgs.password},
+		{"dbname", connArgs.database},
+		{"service", connArgs.service},
+		{"servicefile", serviceFile(connArgs.service)},
+		{"sslmode", connArgs.sslMode},
+		{"sslrootcert", connArgs.sslRootCert},
+		{"krbsrvname", connArgs.krbSrvName},
+		{"krbspn", connArgs.krbSPN},
+		{"target_session_attrs", connArgs.targetSessionAttrs},
+		{"application_name", connArgs.applicationName},
+	}
+	var params []connParam
+	for _, p := range all {
+		value := p.value
+		if !verbatimParams[p.key] {
+			value = trim(value)
+		}
+		if value != "" {
+			params = append(params, connParam{p.key, value})
+		}
+	}
+	return params
+}
+
+// verbatimParams are passed on as given, whitespace can be part of a
+// password, user or database name.
+var verbatimParams = map[string]bool{"user": true, "password": true, "dbname": true}
+
+// socketHost returns the host and port for --socket, which is either the
+// socket directory or the socket file like /tmp/.s.PGSQL.5433.
+func socketHost(socket, port string) (string, string) {
+	dir, file := filepath.Split(socket)
+	if p, ok := strings.CutPrefix(file, ".s.PGSQL."); ok {
+		return filepath.Clean(dir), p
+	}
+	return socket, port
+}
+
+// withConnParams adds parameters to a URL or keyword/value connection
+// string, replacing the ones it already has.
+func withConnParams(connStr string, params []connParam) (string, error) {
+	if len(params) == 0 {
+		return connStr, nil
+	}
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", err
+		}
+		q := u.Query()
+		for _, p := range params {
+			q.Set(p.key, p.value)
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	// Later keywords win, so they can simply be appended.
+	parts := []string{}
+	if connStr != "" {
+		parts = append(parts, connStr)
+	}
+	for _, p := range params {
+		parts = append(parts, p.key+"="+quoteConnValue(p.value))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// serviceFile returns the file defining a connection service. Like libpq,
+// the user's file is searched before the system wide pg_service.conf in
+// $PGSYSCONFDIR. It is empty when pgx's default lookup should apply.
+func serviceFile(service string) string {
+	if service == "" {
+		return ""
+	}
+	var files []string
+	if path := os.Getenv("PGSERVICEFILE"); path != "" {
+		files = append(files, path)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".pg_service.conf"))
+	}
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		files = append(files, filepath.Join(dir, "pg_service.conf"))
+	} else {
+		files = append(files, "/etc/postgresql-common/pg_service.conf", "/etc/pg_service.conf")
+	}
+	for _, file := range files {
+		if sf, err := pgservicefile.ReadServicefile(file); err == nil {
+			if _, err := sf.GetService(service); err == nil {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+// quoteConnValue quotes a value of a keyword/value connection string.
+func quoteConnValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sessionLifetime keeps the connection of --single-connection open for the
+// whole run, pgxpool closes connections after an hour or half an hour idle
+// by default.
+const sessionLifetime = 100 * 365 * 24 * time.Hour
+
+// cancelDeadline is how long a cancelled statement of --single-connection
+// waits for the server to answer the cancel request before the connection
+// is closed.
+const cancelDeadline = 10 * time.Second
+
+// connectRetrying connects and pings the server, retrying transient
+// failures up to --connect-retries times. The wait starts at
+// --connect-backoff and doubles after every attempt.
+func connectRetrying(ctx context.Context, config *pgxpool.Config, connArgs connArgs) (*pgxpool.Pool, error) {
+	backoff := connArgs.connectBackoff
+	for attempt := 0; ; attempt++ {
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		err = pool.Ping(ctx)
+		if err == nil {
+			return pool, nil
+		}
+		pool.Close()
+		if !isTransient(err) || attempt == connArgs.connectRetries {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v, retrying in %s (%d/%d)\n", err, backoff, attempt+1, connArgs.connectRetries)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether a connection failure may go away by itself,
+// like refused connections, timeouts or failed DNS lookups while a server
+// starts or fails over. Errors from the server are final, except for it
+// starting up, shutting down or rejecting connections. Anything else, like
+// TLS or configuration errors, fails right away.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "57P01" || pgErr.Code == "57P03" || strings.HasPrefix(pgErr.Code, "08")
+	}
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// The server closed the connection during the startup.
+		return true
+	case pgconn.Timeout(err), errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
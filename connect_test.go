@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{&net.DNSError{Err: "no such host", Name: "db", IsNotFound: true}, true},
+		{fmt.Errorf("failed to receive message: %w", io.ErrUnexpectedEOF), true},
+		{&pgconn.PgError{Code: "57P03"}, true},
+		{&pgconn.PgError{Code: "28P01"}, false},
+		{errors.New("server refused TLS connection"), false},
+		{errors.New("server version 13.4 doesn't satisfy --require-version >=14"), false},
+		{&tls.CertificateVerificationError{}, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
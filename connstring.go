@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// jdbcParams maps the JDBC driver parameters to libpq ones.
+var jdbcParams = map[string]string{
+	"user":             "user",
+	"password":         "password",
+	"sslmode":          "sslmode",
+	"sslrootcert":      "sslrootcert",
+	"sslcert":          "sslcert",
+	"sslkey":           "sslkey",
+	"connectTimeout":   "connect_timeout",
+	"ApplicationName":  "application_name",
+	"currentSchema":    "search_path",
+	"targetServerType": "target_session_attrs",
+}
+
+// jdbcServerTypes maps targetServerType values to target_session_attrs.
+var jdbcServerTypes = map[string]string{
+	"any":             "any",
+	"primary":         "read-write",
+	"master":          "read-write",
+	"secondary":       "standby",
+	"slave":           "standby",
+	"preferSecondary": "prefer-standby",
+	"preferSlave":     "prefer-standby",
+}
+
+// normalizeConnString accepts connection strings as they are pasted from
+// other tools: postgres:// and postgresql:// URLs and keyword/value DSNs
+// as pgx does, JDBC URLs and unix:// URLs naming a socket directory or
+// file. Surrounding quotes are dropped.
+func normalizeConnString(s string) (string, error) {
+	s = trim(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	switch {
+	case strings.HasPrefix(s, "jdbc:postgresql:"):
+		return jdbcConnString(s)
+	case strings.HasPrefix(s, "unix:"):
+		return unixConnString(s)
+	}
+	return s, nil
+}
+
+// jdbcConnString converts jdbc:postgresql://host:port/db?user=...
+// Parameters without libpq equivalent are dropped with a warning, the
+// server would reject them as unknown settings.
+func jdbcConnString(s string) (string, error) {
+	u, err := url.Parse(strings.TrimPrefix(s, "jdbc:"))
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	params := url.Values{}
+	for _, key := range queryKeys(q) {
+		value := q.Get(key)
+		switch {
+		case key == "ssl":
+			if value == "true" && q.Get("sslmode") == "" {
+				params.Set("sslmode", "require")
+			}
+		case key == "targetServerType":
+			attrs, ok := jdbcServerTypes[value]
+			if !ok {
+				return "", fmt.Errorf("unknown targetServerType %q", value)
+			}
+			params.Set("target_session_attrs", attrs)
+		case jdbcParams[key] != "":
+			params.Set(jdbcParams[key], value)
+		default:
+			fmt.Fprintf(os.Stderr, "warning: ignoring JDBC parameter %s\n", key)
+		}
+	}
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+// unixConnString converts unix:///var/run/postgresql?dbname=shop, with the
+// path being the socket directory or the socket file itself, into
+// keyword/value form as URLs can't hold a socket path as host.
+func unixConnString(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if u.Path == "" {
+		return "", fmt.Errorf("no socket path in %s", s)
+	}
+	host, port := socketHost(u.Path, "")
+	parts := []string{"host=" + quoteConnValue(host)}
+	if port != "" {
+		parts = append(parts, "port="+quoteConnValue(port))
+	}
+	if u.User != nil {
+		parts = append(parts, "user="+quoteConnValue(u.User.Username()))
+		if password, ok := u.User.Password(); ok {
+			parts = append(parts, "password="+quoteConnValue(password))
+		}
+	}
+	q := u.Query()
+	for _, key := range queryKeys(q) {
+		parts = append(parts, key+"="+quoteConnValue(q.Get(key)))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// queryKeys returns the parameters of a URL query in lexical order.
+func queryKeys(q url.Values) []string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestNormalizeConnString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"postgres://app@localhost/app", "postgres://app@localhost/app"},
+		{"host=localhost dbname=app", "host=localhost dbname=app"},
+		{`"postgres://app@localhost/app"`, "postgres://app@localhost/app"},
+		{" 'host=localhost' ", "host=localhost"},
+		{"jdbc:postgresql://db:5433/shop?user=app&password=secret", "postgresql://db:5433/shop?password=secret&user=app"},
+		{"jdbc:postgresql://db/shop?ssl=true&ApplicationName=etl", "postgresql://db/shop?application_name=etl&sslmode=require"},
+		{"jdbc:postgresql://db/shop?ssl=true&sslmode=verify-full", "postgresql://db/shop?sslmode=verify-full"},
+		{"jdbc:postgresql://db/shop?targetServerType=primary", "postgresql://db/shop?target_session_attrs=read-write"},
+		{"unix:///var/run/postgresql?dbname=shop", "host='/var/run/postgresql' dbname='shop'"},
+		{"unix:///tmp/.s.PGSQL.5433", "host='/tmp' port='5433'"},
+		{"unix://app:pw@/tmp?dbname=shop", "host='/tmp' user='app' password='pw' dbname='shop'"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeConnString(tt.in)
+		if err != nil {
+			t.Errorf("normalizeConnString(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeConnString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	for _, in := range []string{"jdbc:postgresql://db/shop?targetServerType=nearest", "unix://"} {
+		if _, err := normalizeConnString(in); err == nil {
+			t.Errorf("normalizeConnString(%q) succeeded, want an error", in)
+		}
+	}
+}
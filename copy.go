@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// copyDirection returns "to" for COPY ... TO STDOUT and "from" for
+// COPY ... FROM STDIN statements, and "" for anything else. Words inside
+// the parentheses of COPY (query) TO are skipped.
+func copyDirection(sql string) string {
+	if firstKeyword(sql) != "copy" {
+		return ""
+	}
+	var words []string
+	depth := 0
+	for _, seg := range scanSQL(sql) {
+		switch seg.kind {
+		case commentSegment:
+			continue
+		case quotedSegment:
+			if depth == 0 {
+				words = append(words, "")
+			}
+			continue
+		}
+		start := -1
+		for i := 0; i <= len(seg.text); i++ {
+			if i < len(seg.text) && isIdentByte(seg.text[i]) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 && depth == 0 {
+				words = append(words, strings.ToLower(seg.text[start:i]))
+			}
+			start = -1
+			if i < len(seg.text) {
+				switch seg.text[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+			}
+		}
+	}
+	for i := 1; i < len(words); i++ {
+		switch {
+		case words[i-1] == "to" && words[i] == "stdout":
+			return "to"
+		case words[i-1] == "from" && words[i] == "stdin":
+			return "from"
+		}
+	}
+	return ""
+}
+
+// copyConn returns the connection of an executor, COPY bypasses the query
+// methods and talks to it directly.
+func copyConn(ex Executor, stmt sqlStatement) (*pgconn.PgConn, error) {
+	if len(stmt.args) > 0 {
+		return nil, errors.New("COPY doesn't take parameters")
+	}
+	c, ok := ex.(interface{ Conn() *pgx.Conn })
+	if !ok {
+		return nil, errors.New("COPY needs a single connection")
+	}
+	return c.Conn().PgConn(), nil
+}
+
+// execCopyOut streams the data of a COPY ... TO STDOUT statement to out
+// as the server sends it, in any of the COPY formats.
+func execCopyOut(ctx context.Context, ex Executor, out io.Writer, stmt sqlStatement) (pgconn.CommandTag, error) {
+	pgConn, err := copyConn(ex, stmt)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgConn.CopyTo(ctx, out, stmt.sql)
+}
+
+// execCopyIn streams stdin, or the --input file, to a COPY ... FROM STDIN
+// statement.
+func execCopyIn(ctx context.Context, ex Executor, stmt sqlStatement) (pgconn.CommandTag, error) {
+	pgConn, err := copyConn(ex, stmt)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	in := os.Stdin
+	if stmt.input != "-" {
+		if in, err = os.Open(stmt.input); err != nil {
+			return pgconn.CommandTag{}, err
+		}
+		defer in.Close()
+	}
+	return pgConn.CopyFrom(ctx, in, stmt.sql)
+}
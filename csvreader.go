@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvReader reads RFC 4180 records like encoding/csv, but also reports
+// which fields were quoted. Like COPY in CSV format, only an unquoted
+// field matching the NULL marker is NULL, so "" stays an empty string.
+type csvReader struct {
+	r     *bufio.Reader
+	comma rune
+	line  int
+	// fields is the number of fields of the first record, which all
+	// others must have too.
+	fields int
+}
+
+func newCSVReader(r io.Reader, comma rune) *csvReader {
+	return &csvReader{r: bufio.NewReader(r), comma: comma}
+}
+
+// Read returns the next record, whether each field was quoted and the line
+// the record starts on. Empty lines are skipped, at the end it returns
+// io.EOF.
+func (r *csvReader) Read() (record []string, quoted []bool, line int, err error) {
+	for {
+		record, quoted, line, err = r.readRecord()
+		if err != nil {
+			return nil, nil, line, err
+		}
+		if record == nil {
+			continue
+		}
+		if r.fields == 0 {
+			r.fields = len(record)
+		} else if len(record) != r.fields {
+			return nil, nil, line, fmt.Errorf("line %d: wrong number of fields, expected %d", line, r.fields)
+		}
+		return record, quoted, line, nil
+	}
+}
+
+// readRecord reads one record, or returns nil for an empty line.
+func (r *csvReader) readRecord() ([]string, []bool, int, error) {
+	r.line++
+	start := r.line
+	c, _, err := r.r.ReadRune()
+	if err != nil {
+		return nil, nil, start, err
+	}
+	if c == '\r' {
+		r.skipNewline()
+	}
+	if c == '\n' || c == '\r' {
+		return nil, nil, start, nil
+	}
+	r.r.UnreadRune()
+
+	var record []string
+	var quoted []bool
+	var field strings.Builder
+	for {
+		field.Reset()
+		c, _, err = r.r.ReadRune()
+		isQuoted := err == nil && c == '"'
+		if isQuoted {
+			for {
+				if c, _, err = r.r.ReadRune(); err != nil {
+					if errors.Is(err, io.EOF) {
+						return nil, nil, start, fmt.Errorf("line %d: unterminated quoted field", start)
+					}
+					return nil, nil, start, err
+				}
+				if c == '"' {
+					if c, _, err = r.r.ReadRune(); err != nil || c != '"' {
+						break
+					}
+				} else if c == '\n' {
+					r.line++
+				}
+				field.WriteRune(c)
+			}
+		} else {
+			for err == nil && c != r.comma && c != '\n' && c != '\r' {
+				if c == '"' {
+					return nil, nil, start, fmt.Errorf("line %d: bare quote in unquoted field", r.line)
+				}
+				field.WriteRune(c)
+				c, _, err = r.r.ReadRune()
+			}
+		}
+		record = append(record, field.String())
+		quoted = append(quoted, isQuoted)
+		switch {
+		case errors.Is(err, io.EOF):
+			return record, quoted, start, nil
+		case err != nil:
+			return nil, nil, start, err
+		case c == r.comma:
+		case c == '\r':
+			r.skipNewline()
+			return record, quoted, start, nil
+		case c == '\n':
+			return record, quoted, start, nil
+		default:
+			return nil, nil, start, fmt.Errorf("line %d: unexpected %q after quoted field", r.line, c)
+		}
+	}
+}
+
+// skipNewline consumes the \n of a \r\n line break. A lone \r ends the
+// line as well.
+func (r *csvReader) skipNewline() {
+	if c, _, err := r.r.ReadRune(); err == nil && c != '\n' {
+		r.r.UnreadRune()
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVReader(t *testing.T) {
+	in := "a,b,c\r\n\"x,y\",\"say \"\"hi\"\"\",\n\n\"\",\"two\nlines\",z\r\n1,2,3"
+	want := []struct {
+		record []string
+		quoted []bool
+		line   int
+	}{
+		{[]string{"a", "b", "c"}, []bool{false, false, false}, 1},
+		{[]string{"x,y", `say "hi"`, ""}, []bool{true, true, false}, 2},
+		{[]string{"", "two\nlines", "z"}, []bool{true, true, false}, 4},
+		{[]string{"1", "2", "3"}, []bool{false, false, false}, 6},
+	}
+	r := newCSVReader(strings.NewReader(in), ',')
+	for _, w := range want {
+		record, quoted, line, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(record, w.record) || !reflect.DeepEqual(quoted, w.quoted) || line != w.line {
+			t.Errorf("Read() = %q, %v, line %d, want %q, %v, line %d", record, quoted, line, w.record, w.quoted, w.line)
+		}
+	}
+	if _, _, _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("Read() at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestCSVReaderErrors(t *testing.T) {
+	for _, in := range []string{
+		"a,b\n1\n",
+		"a,b\n1,\"open\n",
+		"a,b\n1,x\"y\n",
+		"a,b\n1,\"x\"y\n",
+	} {
+		r := newCSVReader(strings.NewReader(in), ',')
+		var err error
+		for err == nil {
+			_, _, _, err = r.Read()
+		}
+		if errors.Is(err, io.EOF) {
+			t.Errorf("reading %q succeeded, want an error", in)
+		}
+	}
+}
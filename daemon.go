@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/urfave/cli/v2"
+)
+
+// daemonReset undoes what a client changed in a session before it is
+// handed to the next one: settings, SET ROLE, prepared statements,
+// temporary tables and so on.
+const daemonReset = "DISCARD ALL"
+
+// daemonParams are the startup parameters of a client that aren't session
+// settings.
+var daemonParams = map[string]bool{"user": true, "database": true, "options": true, "replication": true}
+
+// reportedParams are sent to clients on startup like the server does,
+// with the values of the session they get.
+var reportedParams = []string{
+	"server_version", "server_encoding", "client_encoding", "application_name",
+	"DateStyle", "IntervalStyle", "TimeZone", "integer_datetimes",
+	"standard_conforming_strings", "is_superuser", "session_authorization",
+	"default_transaction_read_only", "in_hot_standby",
+}
+
+func daemonCommand(args *connArgs) *cli.Command {
+	var listen string
+	return &cli.Command{
+		Name:      "daemon",
+		Usage:     "Keep connections open behind a Unix socket for other pgexec runs",
+		UsageText: "pgexec daemon --url \"postgres://...\" &\nexport PGEXEC_DAEMON=$XDG_RUNTIME_DIR/pgexec.sock",
+		Flags: append(connFlags(args),
+			&cli.StringFlag{
+				Name:        "listen",
+				Destination: &listen,
+				Usage:       "Socket path, defaults to $PGEXEC_DAEMON or pgexec.sock in $XDG_RUNTIME_DIR",
+			},
+		),
+		Action: func(cCtx *cli.Context) error {
+			if err := applyProfile(cCtx, args.profile); err != nil {
+				return err
+			}
+			if err := loadEnvFile(args); err != nil {
+				return err
+			}
+			return runDaemon(cCtx.Context, *args, listen)
+		},
+	}
+}
+
+// defaultDaemonSocket returns the socket path of the daemon, in the
+// private runtime directory of the user if there is one, otherwise in a
+// directory of the user in the temporary directory.
+func defaultDaemonSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pgexec.sock")
+	}
+	return filepath.Join(os.TempDir(), "pgexec-"+strconv.Itoa(os.Getuid()), "pgexec.sock")
+}
+
+// privateDir creates dir, or checks that the existing one is only
+// accessible by the user, as anyone who can get into it could take over
+// the socket.
+func privateDir(dir string) error {
+	if err := os.Mkdir(dir, 0o700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if uid, ok := fileOwner(info); !info.IsDir() || info.Mode().Perm() != 0o700 || ok && uid != os.Getuid() {
+		return fmt.Errorf("%s must be a directory only accessible by the user", dir)
+	}
+	return nil
+}
+
+// listenPrivate listens on a Unix socket only the user can connect to. It
+// is created in a private directory and moved into place, so it is never
+// reachable with the permissions of the umask. A socket left behind by a
+// daemon that was killed is replaced, other files are not.
+func listenPrivate(path string) (*net.UnixListener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if uid, ok := fileOwner(info); info.Mode()&fs.ModeSocket == 0 || ok && uid != os.Getuid() {
+			return nil, fmt.Errorf("%s exists and is not a socket of the user", path)
+		}
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".pgexec-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed by its final path.
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// daemonSocket returns the socket of the daemon to run through, from
+// --daemon or $PGEXEC_DAEMON. Runs that name a database of their own with
+// connection flags bypass a daemon from the environment, which serves
+// another one.
+func daemonSocket(connArgs connArgs) (string, error) {
+	flag := explicitConnFlag(connArgs)
+	if connArgs.daemon != "" {
+		if flag != "" {
+			return "", fmt.Errorf("--%s can't be combined with --daemon, the daemon decides where to connect", flag)
+		}
+		return connArgs.daemon, nil
+	}
+	if flag != "" {
+		return "", nil
+	}
+	return os.Getenv("PGEXEC_DAEMON"), nil
+}
+
+// daemonPoolConfig connects to the daemon instead of the database. The
+// daemon has authenticated already, so only the session settings of the
+// connection flags apply.
+func daemonPoolConfig(connArgs connArgs, socket string) (*pgxpool.Config, error) {
+	config, err := pgxpool.ParseConfig("sslmode=disable")
+	if err != nil {
+		return nil, err
+	}
+	cc := config.ConnConfig
+	cc.Fallbacks = nil
+	cc.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+	cc.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	cc.RuntimeParams["application_name"] = "pgexec"
+	if connArgs.applicationName != "" {
+		cc.RuntimeParams["application_name"] = connArgs.applicationName
+	}
+	return config, nil
+}
+
+// daemon hands the connections of its pool to the clients connecting to
+// the socket, one session per client.
+type daemon struct {
+	pool         *pgxpool.Pool
+	afterConnect func(context.Context, *pgx.Conn) error
+
+	mu sync.Mutex
+	// sessions are the server connections in use by PID, for cancel
+	// requests.
+	sessions map[uint32]*pgconn.PgConn
+}
+
+// runDaemon connects to the database and serves the socket until it is
+// interrupted. The socket is only accessible by the user, as it hands out
+// authenticated sessions.
+func runDaemon(ctx context.Context, connArgs connArgs, listen string) error {
+	if listen == "" {
+		listen = connArgs.daemon
+	}
+	if listen == "" {
+		listen = os.Getenv("PGEXEC_DAEMON")
+	}
+	if listen == "" {
+		listen = defaultDaemonSocket()
+		if err := privateDir(filepath.Dir(listen)); err != nil {
+			return err
+		}
+	}
+	// The daemon itself connects to the database.
+	connArgs.daemon = ""
+	os.Unsetenv("PGEXEC_DAEMON")
+	// Prepared statements of the daemon would be dropped by daemonReset.
+	connArgs.poolerCompat = true
+
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := pool.Ping(ctx); err != nil {
+		return err
+	}
+
+	if c, err := net.Dial("unix", listen); err == nil {
+		c.Close()
+		return fmt.Errorf("a daemon is already listening on %s", listen)
+	}
+	ln, err := listenPrivate(listen)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(listen)
+	defer ln.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	fmt.Fprintf(os.Stderr, "listening on %s\n", listen)
+
+	d := &daemon{
+		pool:         pool,
+		afterConnect: pool.Config().AfterConnect,
+		sessions:     map[uint32]*pgconn.PgConn{},
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.serve(ctx, client)
+		}()
+	}
+}
+
+// serve answers the startup of a client itself and then relays the
+// protocol between the client and a session of the pool.
+func (d *daemon) serve(ctx context.Context, client net.Conn) {
+	defer client.Close()
+	backend := pgproto3.NewBackend(client, client)
+	var startup *pgproto3.StartupMessage
+	for startup == nil {
+		msg, err := backend.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			// The socket is local, there is nothing to encrypt.
+			if _, err := client.Write([]byte{'N'}); err != nil {
+				return
+			}
+		case *pgproto3.CancelRequest:
+			d.cancel(ctx, msg)
+			return
+		case *pgproto3.StartupMessage:
+			startup = msg
+		}
+	}
+
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		daemonError(backend, err)
+		return
+	}
+	clean := false
+	defer func() {
+		d.release(ctx, conn, clean)
+	}()
+	for k, v := range startup.Parameters {
+		if daemonParams[k] {
+			continue
+		}
+		if _, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", k, v); err != nil {
+			daemonError(backend, err)
+			return
+		}
+	}
+	pgConn := conn.Conn().PgConn()
+	if err := pgConn.SyncConn(ctx); err != nil {
+		daemonError(backend, err)
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	for _, key := range reportedParams {
+		if v := pgConn.ParameterStatus(key); v != "" {
+			backend.Send(&pgproto3.ParameterStatus{Name: key, Value: v})
+		}
+	}
+	// The key of the server session, so cancel requests can be forwarded.
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: pgConn.PID(), SecretKey: pgConn.SecretKey()})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.sessions[pgConn.PID()] = pgConn
+	d.mu.Unlock()
+	clean = relay(client, pgConn.Conn())
+	d.mu.Lock()
+	delete(d.sessions, pgConn.PID())
+	d.mu.Unlock()
+}
+
+// cancel forwards a cancel request to the server session of a client.
+func (d *daemon) cancel(ctx context.Context, req *pgproto3.CancelRequest) {
+	d.mu.Lock()
+	pgConn := d.sessions[req.ProcessID]
+	d.mu.Unlock()
+	if pgConn != nil && pgConn.SecretKey() == req.SecretKey {
+		pgConn.CancelRequest(ctx)
+	}
+}
+
+// release resets a session and returns it to the pool. Sessions that
+// didn't end cleanly, e.g. because the client died with a query running,
+// are closed instead.
+func (d *daemon) release(ctx context.Context, conn *pgxpool.Conn, clean bool) {
+	if clean {
+		_, err := conn.Exec(ctx, daemonReset)
+		if err == nil && d.afterConnect != nil {
+			err = d.afterConnect(ctx, conn.Conn())
+		}
+		if err == nil {
+			conn.Release()
+			return
+		}
+	}
+	closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conn.Conn().Close(closeCtx)
+	conn.Release()
+}
+
+// daemonError reports a failed startup to the client.
+func daemonError(backend *pgproto3.Backend, err error) {
+	msg := &pgproto3.ErrorResponse{Severity: "FATAL", Code: "08006", Message: err.Error()}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		msg.Code, msg.Message = pgErr.Code, pgErr.Message
+	}
+	backend.Send(msg)
+	backend.Flush()
+}
+
+// relay copies the messages between a client and its server session until
+// the client terminates. It reports whether the session ended cleanly,
+// with no query running and outside a transaction, so it can be reused.
+func relay(client, server net.Conn) bool {
+	var mu sync.Mutex
+	// Query, Sync and FunctionCall messages wait for a ReadyForQuery.
+	pending := 0
+	txStatus := byte('I')
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyMessages(client, server, func(typ byte, body []byte) bool {
+			if typ == 'Z' && len(body) == 1 {
+				mu.Lock()
+				pending--
+				txStatus = body[0]
+				mu.Unlock()
+			}
+			return true
+		})
+	}()
+	terminated := false
+	copyMessages(server, client, func(typ byte, _ []byte) bool {
+		switch typ {
+		case 'X':
+			// Kept from the server, the session outlives the client.
+			terminated = true
+			return false
+		case 'Q', 'S', 'F':
+			mu.Lock()
+			pending++
+			mu.Unlock()
+		}
+		return true
+	})
+	// Nothing is expected from the server anymore, stop waiting for it.
+	server.SetReadDeadline(time.Now())
+	<-done
+	server.SetReadDeadline(time.Time{})
+	mu.Lock()
+	defer mu.Unlock()
+	return terminated && pending == 0 && txStatus == 'I'
+}
+
+// copyMessages copies protocol messages from src to dst until keep returns
+// false for one, which isn't copied, or either side fails.
+func copyMessages(dst io.Writer, src io.Reader, keep func(typ byte, body []byte) bool) error {
+	r := bufio.NewReader(src)
+	w := bufio.NewWriter(dst)
+	header := make([]byte, 5)
+	var body []byte
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint32(header[1:])) - 4
+		if n < 0 {
+			return fmt.Errorf("invalid message length %d", n)
+		}
+		if cap(body) < n {
+			body = make([]byte, n)
+		}
+		body = body[:n]
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		if !keep(header[0], body) {
+			return w.Flush()
+		}
+		w.Write(header)
+		w.Write(body)
+		// Batch the writes of messages that arrived together.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relationKinds are the pg_class.relkind values listed by the \d commands.
+var relationKinds = map[string]string{
+	`\d`:  "'r', 'p', 'v', 'm', 'S', 'f'",
+	`\dt`: "'r', 'p'",
+	`\dv`: "'v'",
+	`\dm`: "'m'",
+	`\di`: "'i', 'I'",
+	`\ds`: "'S'",
+}
+
+const relationList = `SELECT n.nspname AS "Schema", c.relname AS "Name",
+	CASE c.relkind WHEN 'r' THEN 'table' WHEN 'p' THEN 'partitioned table' WHEN 'v' THEN 'view'
+		WHEN 'm' THEN 'materialized view' WHEN 'i' THEN 'index' WHEN 'I' THEN 'partitioned index'
+		WHEN 'S' THEN 'sequence' WHEN 'f' THEN 'foreign table' END AS "Type",
+	pg_catalog.pg_get_userbyid(c.relowner) AS "Owner"
+FROM pg_catalog.pg_class c
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN (%s) AND %s
+ORDER BY 1, 2`
+
+const describeColumns = `SELECT a.attname AS "Column", pg_catalog.format_type(a.atttypid, a.atttypmod) AS "Type",
+	CASE WHEN a.attnotnull THEN 'not null' ELSE '' END AS "Nullable",
+	pg_catalog.pg_get_expr(d.adbin, d.adrelid) AS "Default"
+FROM pg_catalog.pg_attribute a
+LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE a.attrelid = %[1]s::regclass AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum;
+SELECT i.indexrelid::regclass::text AS "Index", pg_catalog.pg_get_indexdef(i.indexrelid) AS "Definition"
+FROM pg_catalog.pg_index i
+WHERE i.indrelid = %[1]s::regclass
+ORDER BY 1`
+
+const schemaList = `SELECT n.nspname AS "Name", pg_catalog.pg_get_userbyid(n.nspowner) AS "Owner"
+FROM pg_catalog.pg_namespace n
+WHERE %s
+ORDER BY 1`
+
+const databaseList = `SELECT d.datname AS "Name", pg_catalog.pg_get_userbyid(d.datdba) AS "Owner",
+	pg_catalog.pg_encoding_to_char(d.encoding) AS "Encoding", d.datcollate AS "Collate"
+FROM pg_catalog.pg_database d
+WHERE %s
+ORDER BY 1`
+
+const functionList = `SELECT n.nspname AS "Schema", p.proname AS "Name",
+	pg_catalog.pg_get_function_result(p.oid) AS "Result data type",
+	pg_catalog.pg_get_function_arguments(p.oid) AS "Argument data types"
+FROM pg_catalog.pg_proc p
+JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+WHERE %s
+ORDER BY 1, 2, 4`
+
+const roleList = `SELECT r.rolname AS "Role name", r.rolsuper AS "Superuser", r.rolcreaterole AS "Create role",
+	r.rolcreatedb AS "Create DB", r.rolcanlogin AS "Login"
+FROM pg_catalog.pg_roles r
+WHERE %s
+ORDER BY 1`
+
+// userSchemas hides the system schemas when no pattern is given, like psql.
+const userSchemas = "n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname !~ '^pg_toast'"
+
+// describeQuery translates the psql meta-commands \d, \dt, \dv, \dm, \di,
+// \ds, \df, \dn, \du and \l into catalog queries. pattern may use * and ?
+// wildcards and a schema, like psql. \d with a relation name describes its
+// columns and indexes.
+func describeQuery(cmd, pattern string) (string, bool) {
+	switch cmd {
+	case `\d`, `\dt`, `\dv`, `\dm`, `\di`, `\ds`:
+		if cmd == `\d` && pattern != "" && !strings.ContainsAny(pattern, "*?") {
+			return fmt.Sprintf(describeColumns, quoteLiteral(pattern)), true
+		}
+		filter := patternFilter(pattern, "n.nspname", "c.relname", userSchemas+" AND pg_catalog.pg_table_is_visible(c.oid)")
+		return fmt.Sprintf(relationList, relationKinds[cmd], filter), true
+	case `\df`:
+		filter := patternFilter(pattern, "n.nspname", "p.proname", userSchemas+" AND pg_catalog.pg_function_is_visible(p.oid)")
+		return fmt.Sprintf(functionList, filter), true
+	case `\dn`:
+		return fmt.Sprintf(schemaList, patternFilter(pattern, "", "n.nspname", "n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'")), true
+	case `\du`:
+		return fmt.Sprintf(roleList, patternFilter(pattern, "", "r.rolname", "r.rolname !~ '^pg_'")), true
+	case `\l`:
+		return fmt.Sprintf(databaseList, patternFilter(pattern, "", "d.datname", "true")), true
+	}
+	return "", false
+}
+
+// patternFilter matches a psql pattern against the name column, and the
+// schema column if the pattern is qualified. Without a pattern the default
+// condition applies.
+func patternFilter(pattern, schemaCol, nameCol, def string) string {
+	if pattern == "" {
+		return def
+	}
+	schema, name, qualified := strings.Cut(pattern, ".")
+	if !qualified || schemaCol == "" {
+		return nameCol + " LIKE " + likePattern(pattern)
+	}
+	return schemaCol + " LIKE " + likePattern(schema) + " AND " + nameCol + " LIKE " + likePattern(name)
+}
+
+// likePattern converts a psql pattern into a LIKE literal. Unquoted
+// patterns are folded to lower case like identifiers.
+func likePattern(pattern string) string {
+	if strings.HasPrefix(pattern, `"`) && strings.HasSuffix(pattern, `"`) && len(pattern) >= 2 {
+		pattern = pattern[1 : len(pattern)-1]
+	} else {
+		pattern = strings.ToLower(pattern)
+	}
+	var b strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '\\', '%', '_':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '*':
+			b.WriteRune('%')
+		case '?':
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return quoteLiteral(b.String())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLikePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"users", "'users'"},
+		{"Users", "'users'"},
+		{`"Users"`, "'Users'"},
+		{"user*", "'user%'"},
+		{"us?r", "'us_r'"},
+		{"my_table%", `'my\_table\%'`},
+		{"o'brien", "'o''brien'"},
+	}
+	for _, tt := range tests {
+		if got := likePattern(tt.pattern); got != tt.want {
+			t.Errorf("likePattern(%q) = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeQuery(t *testing.T) {
+	tests := []struct {
+		cmd, pattern string
+		contains     []string
+	}{
+		{`\dt`, "", []string{userSchemas, "pg_table_is_visible"}},
+		{`\dt`, "sales.order*", []string{"n.nspname LIKE 'sales' AND c.relname LIKE 'order%'"}},
+		{`\d`, "orders", []string{"'orders'"}},
+		{`\d`, "ord*", []string{"c.relname LIKE 'ord%'"}},
+		{`\df`, "", []string{"pg_function_is_visible"}},
+		{`\dn`, "pub*", []string{"n.nspname LIKE 'pub%'"}},
+		{`\du`, "", []string{"r.rolname !~ '^pg_'"}},
+		{`\l`, "shop.x", []string{"d.datname LIKE 'shop.x'"}},
+	}
+	for _, tt := range tests {
+		query, ok := describeQuery(tt.cmd, tt.pattern)
+		if !ok {
+			t.Errorf("describeQuery(%s, %q) is not supported", tt.cmd, tt.pattern)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(query, s) {
+				t.Errorf("describeQuery(%s, %q) = %s, want it to contain %s", tt.cmd, tt.pattern, query, s)
+			}
+		}
+	}
+	if _, ok := describeQuery(`\dx`, ""); ok {
+		t.Error(`describeQuery(\dx) is supported, want it not to be`)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// docWriter renders the result set as a table for org-mode, AsciiDoc or
+// LaTeX documents. Rows are buffered because org tables are aligned.
+type docWriter struct {
+	out    *bufio.Writer
+	values *valueFormat
+	format string
+	fields []pgconn.FieldDescription
+	rows   [][]string
+}
+
+var (
+	orgEscaper      = strings.NewReplacer("|", `\vert{}`, "\n", " ")
+	asciidocEscaper = strings.NewReplacer("|", `\|`)
+	latexEscaper    = strings.NewReplacer(
+		`\`, `\textbackslash{}`, "&", `\&`, "%", `\%`, "$", `\$`, "#", `\#`, "_", `\_`,
+		"{", `\{`, "}", `\}`, "~", `\textasciitilde{}`, "^", `\textasciicircum{}`,
+		"|", `\textbar{}`, "<", `\textless{}`, ">", `\textgreater{}`, "\n", " ",
+	)
+)
+
+func (w *docWriter) escape(s string) string {
+	switch w.format {
+	case "org":
+		return orgEscaper.Replace(s)
+	case "asciidoc":
+		return asciidocEscaper.Replace(s)
+	default:
+		return latexEscaper.Replace(s)
+	}
+}
+
+func (w *docWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	return nil
+}
+
+func (w *docWriter) WriteRow(values []any) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = w.escape(w.values.display(w.fields[i], v))
+	}
+	w.rows = append(w.rows, row)
+	return nil
+}
+
+func (w *docWriter) Close() error {
+	header := make([]string, len(w.fields))
+	for i, f := range w.fields {
+		header[i] = w.escape(f.Name)
+	}
+	switch w.format {
+	case "org":
+		w.writeOrg(header)
+	case "asciidoc":
+		w.out.WriteString("[options=\"header\"]\n|===\n")
+		for _, row := range append([][]string{header}, w.rows...) {
+			for i, c := range row {
+				if i > 0 {
+					w.out.WriteByte(' ')
+				}
+				w.out.WriteString("|" + c)
+			}
+			w.out.WriteByte('\n')
+		}
+		w.out.WriteString("|===\n")
+	default:
+		w.out.WriteString(`\begin{tabular}{` + strings.Repeat("l", len(header)) + "}\n\\hline\n")
+		w.out.WriteString(strings.Join(header, " & ") + ` \\` + "\n\\hline\n")
+		for _, row := range w.rows {
+			w.out.WriteString(strings.Join(row, " & ") + ` \\` + "\n")
+		}
+		w.out.WriteString("\\hline\n\\end{tabular}\n")
+	}
+	return w.out.Flush()
+}
+
+func (w *docWriter) writeOrg(header []string) {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, w.rows...) {
+		for i, c := range row {
+			widths[i] = max(widths[i], text.RuneWidthWithoutEscSequences(c))
+		}
+	}
+	writeRow := func(row []string) {
+		for i, c := range row {
+			w.out.WriteString("| " + text.Pad(c, widths[i], ' ') + " ")
+		}
+		w.out.WriteString("|\n")
+	}
+	writeRow(header)
+	for i, width := range widths {
+		if i == 0 {
+			w.out.WriteByte('|')
+		} else {
+			w.out.WriteByte('+')
+		}
+		w.out.WriteString(strings.Repeat("-", width+2))
+	}
+	w.out.WriteString("|\n")
+	for _, row := range w.rows {
+		writeRow(row)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// dryRun prepares every statement without executing it, so syntax errors
+// and references to missing objects are reported without touching any
+// data. Statements that depend on objects created earlier in the same
+// script fail, as those are never created.
+func dryRun(ctx context.Context, pool *pgxpool.Pool, statements []sqlStatement) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	failed := 0
+	for _, stmt := range statements {
+		_, err := conn.Conn().Prepare(ctx, "", stmt.sql)
+		prefix := ""
+		if stmt.file != "" {
+			prefix = stmt.file + ": "
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%sFAIL %s: %v\n", prefix, statementSummary(stmt.sql), err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%sOK   %s\n", prefix, statementSummary(stmt.sql))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d statements failed", failed, len(statements))
+	}
+	return nil
+}
+
+// statementSummary shortens a statement to its first line for status
+// output.
+func statementSummary(sql string) string {
+	line, _, more := strings.Cut(sql, "\n")
+	const maxLen = 60
+	if r := []rune(line); len(r) > maxLen {
+		return string(r[:maxLen]) + "…"
+	}
+	if more {
+		return line + " …"
+	}
+	return line
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// editorCommand returns $VISUAL or $EDITOR, vi if neither is set.
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := trim(os.Getenv(env)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// editText opens text in the editor and returns the edited text. saved is
+// false when the editor exited without writing the file.
+func editText(text string) (edited string, saved bool, err error) {
+	f, err := os.CreateTemp("", "pgexec-*.sql")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", false, err
+	}
+	if err := f.Close(); err != nil {
+		return "", false, err
+	}
+	before, err := os.Stat(f.Name())
+	if err != nil {
+		return "", false, err
+	}
+
+	// Like $PAGER, the editor may contain arguments, e.g. "code --wait".
+	cmd := exec.Command("sh", "-c", editorCommand()+" '"+strings.ReplaceAll(f.Name(), "'", `'\''`)+"'")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", false, err
+	}
+	after, err := os.Stat(f.Name())
+	if err != nil {
+		return "", false, err
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", false, err
+	}
+	return string(b), !after.ModTime().Equal(before.ModTime()) || string(b) != text, nil
+}
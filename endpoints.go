@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// urlFlag collects repeated --url flags. The first one is the connection
+// used by everything but the statements spread across all of them.
+type urlFlag struct {
+	args *connArgs
+}
+
+func (f *urlFlag) Set(value string) error {
+	if len(f.args.urls) == 0 {
+		f.args.url = value
+	}
+	f.args.urls = append(f.args.urls, value)
+	return nil
+}
+
+func (f *urlFlag) String() string {
+	return ""
+}
+
+// endpoint is one of several --url connections.
+type endpoint struct {
+	name      string
+	pool      *pgxpool.Pool
+	latencies []time.Duration
+}
+
+// openEndpoints connects to every --url. The endpoints are named by host,
+// port and database, so passwords don't end up in the report.
+func openEndpoints(ctx context.Context, connArgs connArgs) ([]*endpoint, error) {
+	var endpoints []*endpoint
+	for _, url := range connArgs.urls {
+		connArgs.url = url
+		pool, err := getConnPool(ctx, connArgs)
+		if err != nil {
+			closeEndpoints(endpoints)
+			return nil, err
+		}
+		cc := pool.Config().ConnConfig
+		name := fmt.Sprintf("%s:%d/%s", cc.Host, cc.Port, cc.Database)
+		endpoints = append(endpoints, &endpoint{name: name, pool: pool})
+	}
+	return endpoints, nil
+}
+
+func closeEndpoints(endpoints []*endpoint) {
+	for _, e := range endpoints {
+		e.pool.Close()
+	}
+}
+
+// execRoundRobin runs every statement in its own transaction on the next
+// endpoint in turn and reports the latencies of each endpoint on stderr.
+// With --repeat the runs of the benchmark are spread instead.
+func execRoundRobin(ctx context.Context, endpoints []*endpoint, connArgs connArgs, out io.Writer, outArgs outputArgs, statements []sqlStatement) error {
+	if connArgs.explain || connArgs.analyze {
+		var err error
+		if statements, err = explainStatements(statements, connArgs.explainFormat, connArgs.analyze); err != nil {
+			return err
+		}
+	}
+	if connArgs.repeat > 0 {
+		return benchmarkEndpoints(ctx, endpoints, connArgs, out, statements)
+	}
+	verbose := len(statements) > 1
+	for i, stmt := range statements {
+		e := endpoints[i%len(endpoints)]
+		start := time.Now()
+		if err := execRetrying(ctx, e.pool, connArgs, out, os.Stderr, outArgs, []sqlStatement{stmt}, verbose); err != nil {
+			return fmt.Errorf("%s: %w", e.name, err)
+		}
+		e.latencies = append(e.latencies, time.Since(start))
+	}
+	for _, e := range endpoints {
+		if len(e.latencies) > 0 {
+			fmt.Fprintf(os.Stderr, "%s  statements=%d  %s\n", e.name, len(e.latencies), latencySummary(e.latencies))
+		}
+	}
+	return nil
+}
+
+// benchmarkEndpoints is benchmark with the runs taking turns on the
+// endpoints, the statistics are printed per statement and endpoint.
+func benchmarkEndpoints(ctx context.Context, endpoints []*endpoint, connArgs connArgs, out io.Writer, statements []sqlStatement) error {
+	if connArgs.warmup < 0 {
+		return fmt.Errorf("invalid --warmup %d", connArgs.warmup)
+	}
+	// Indexed by endpoint, then statement.
+	latencies := make([][][]time.Duration, len(endpoints))
+	for i := range latencies {
+		latencies[i] = make([][]time.Duration, len(statements))
+	}
+	for run := 0; run < connArgs.warmup+connArgs.repeat; run++ {
+		e := run % len(endpoints)
+		durations, _, err := benchmarkRun(ctx, endpoints[e].pool, connArgs, statements)
+		if err != nil {
+			return fmt.Errorf("%s: %w", endpoints[e].name, err)
+		}
+		if run < connArgs.warmup {
+			continue
+		}
+		for i, d := range durations {
+			latencies[e][i] = append(latencies[e][i], d)
+		}
+	}
+
+	for i, stmt := range statements {
+		fmt.Fprintln(out, statementSummary(stmt.sql))
+		for e, endpoint := range endpoints {
+			if len(latencies[e][i]) > 0 {
+				fmt.Fprintf(out, "  %s  runs=%d  %s\n", endpoint.name, len(latencies[e][i]), latencySummary(latencies[e][i]))
+			}
+		}
+	}
+	return nil
+}
+
+// checkEndpointArgs rejects the modes that don't make sense spread across
+// several --url endpoints.
+func checkEndpointArgs(connArgs connArgs) error {
+	switch {
+	case connArgs.dryRun:
+		return errors.New("--dry-run can't be combined with several --url")
+	case connArgs.parallel > 1:
+		return errors.New("--parallel can't be combined with several --url")
+	case connArgs.txPerFile:
+		return errors.New("--tx-per-file can't be combined with several --url, every statement runs in its own transaction")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+// defaultEnvFile is loaded when it exists and no --env-file is given.
+const defaultEnvFile = ".env"
+
+// loadEnvFile sets DATABASE_URL and the PG* variables from --env-file, or
+// from .env in the working directory, so the credentials of an application
+// repo can be used as they are. Other variables are ignored and those
+// already set in the environment win, like with dotenv. DATABASE_URL,
+// from the file or the environment, is used when no connection flag is
+// given at all and no daemon is used.
+func loadEnvFile(connArgs *connArgs) error {
+	path := connArgs.envFile
+	if _, err := os.Stat(defaultEnvFile); path == "" && err == nil {
+		path = defaultEnvFile
+	}
+	if path != "" {
+		vars, err := godotenv.Read(path)
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			if k != "DATABASE_URL" && !strings.HasPrefix(k, "PG") {
+				continue
+			}
+			if _, ok := os.LookupEnv(k); !ok {
+				os.Setenv(k, v)
+			}
+		}
+	}
+	if explicitConnFlag(*connArgs) == "" && connArgs.daemon == "" && os.Getenv("PGEXEC_DAEMON") == "" {
+		connArgs.url = os.Getenv("DATABASE_URL")
+	}
+	return nil
+}
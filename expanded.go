@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// expandedWriter prints every row as a block of column/value lines, like
+// psql's expanded display (\x).
+type expandedWriter struct {
+	out      *bufio.Writer
+	values   *valueFormat
+	fields   []pgconn.FieldDescription
+	keyWidth int
+	rows     int
+	noHeader bool
+}
+
+func (w *expandedWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	for _, f := range fields {
+		w.keyWidth = max(w.keyWidth, text.RuneWidthWithoutEscSequences(f.Name))
+	}
+	return nil
+}
+
+func (w *expandedWriter) WriteRow(values []any) error {
+	w.rows++
+	cells := make([][]string, len(values))
+	valueWidth := 0
+	for i, v := range values {
+		cells[i] = strings.Split(w.values.display(w.fields[i], v), "\n")
+		for _, line := range cells[i] {
+			valueWidth = max(valueWidth, text.RuneWidthWithoutEscSequences(line))
+		}
+	}
+
+	if w.noHeader {
+		if w.rows > 1 {
+			w.out.WriteString("\n")
+		}
+	} else {
+		title := fmt.Sprintf("-[ RECORD %d ]", w.rows)
+		width := w.keyWidth + 3 + valueWidth
+		w.out.WriteString(title)
+		w.out.WriteString(strings.Repeat("-", max(width-len(title), 0)))
+		w.out.WriteString("\n")
+	}
+	for i, lines := range cells {
+		for j, line := range lines {
+			key := ""
+			if j == 0 {
+				key = w.fields[i].Name
+			}
+			key = text.Pad(key, w.keyWidth, ' ')
+			if w.values.color {
+				key = text.Bold.Sprint(key)
+			}
+			w.out.WriteString(key)
+			w.out.WriteString(" | ")
+			w.out.WriteString(line)
+			w.out.WriteString("\n")
+		}
+	}
+	return nil
+}
+
+func (w *expandedWriter) Close() error {
+	if w.rows == 0 && !w.noHeader {
+		w.out.WriteString("(0 rows)\n")
+	}
+	return w.out.Flush()
+}
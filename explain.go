@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// explainable lists the statements EXPLAIN accepts.
+var explainable = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "merge": true,
+	"values": true, "table": true, "with": true, "execute": true, "declare": true,
+}
+
+// explainSettings are the statements that still run with --explain, as they
+// may change the plan of the following ones without touching any data.
+var explainSettings = map[string]bool{"set": true, "reset": true}
+
+// explainStatements wraps the statements EXPLAIN accepts. With --explain
+// nothing may be executed, so other statements than SET and RESET are
+// rejected. With analyze the statements are executed, others run as is and
+// are rolled back with the rest, and unless a format is given their plan
+// is printed as tree.
+func explainStatements(statements []sqlStatement, format string, analyze bool) ([]sqlStatement, error) {
+	switch format {
+	case "", "text", "json":
+	default:
+		return nil, fmt.Errorf("unknown explain format %q", format)
+	}
+	tree := analyze && format == ""
+	if format == "" {
+		format = "text"
+	}
+	options := "FORMAT " + strings.ToUpper(format)
+	switch {
+	case tree:
+		options = "ANALYZE, BUFFERS, FORMAT JSON"
+	case analyze:
+		options = "ANALYZE, BUFFERS, " + options
+	}
+	explained := make([]sqlStatement, len(statements))
+	for i, stmt := range statements {
+		keyword := firstKeyword(stmt.sql)
+		if !analyze && !explainable[keyword] && !explainSettings[keyword] {
+			return nil, fmt.Errorf("--explain can't show the plan of %q and won't execute it, only SET and RESET run as is", statementSummary(stmt.sql))
+		}
+		if explainable[keyword] {
+			stmt.sql = fmt.Sprintf("EXPLAIN (%s) %s", options, stmt.sql)
+			stmt.analyze = tree
+		}
+		explained[i] = stmt
+	}
+	return explained, nil
+}
+
+// explainResult is the output of EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON).
+type explainResult struct {
+	Plan          planNode `json:"Plan"`
+	PlanningTime  float64  `json:"Planning Time"`
+	ExecutionTime float64  `json:"Execution Time"`
+}
+
+type planNode struct {
+	NodeType     string `json:"Node Type"`
+	RelationName string `json:"Relation Name"`
+	Alias        string `json:"Alias"`
+	IndexName    string `json:"Index Name"`
+	JoinType     string `json:"Join Type"`
+	CTEName      string `json:"CTE Name"`
+
+	PlanRows    float64 `json:"Plan Rows"`
+	ActualTime  float64 `json:"Actual Total Time"`
+	ActualRows  float64 `json:"Actual Rows"`
+	ActualLoops float64 `json:"Actual Loops"`
+
+	SharedHitBlocks   int64 `json:"Shared Hit Blocks"`
+	SharedReadBlocks  int64 `json:"Shared Read Blocks"`
+	SharedDirtied     int64 `json:"Shared Dirtied Blocks"`
+	SharedWritten     int64 `json:"Shared Written Blocks"`
+	TempReadBlocks    int64 `json:"Temp Read Blocks"`
+	TempWrittenBlocks int64 `json:"Temp Written Blocks"`
+
+	IndexCond           string  `json:"Index Cond"`
+	HashCond            string  `json:"Hash Cond"`
+	MergeCond           string  `json:"Merge Cond"`
+	JoinFilter          string  `json:"Join Filter"`
+	Filter              string  `json:"Filter"`
+	RowsRemovedByFilter float64 `json:"Rows Removed by Filter"`
+
+	Plans []planNode `json:"Plans"`
+}
+
+// execAnalyze runs an EXPLAIN ANALYZE statement and prints its plan as a
+// tree. Nodes taking a large share of the execution time, row estimates
+// that are far off and blocks read from disk are highlighted.
+func execAnalyze(ctx context.Context, ex Executor, out io.Writer, color bool, stmt sqlStatement) (pgconn.CommandTag, error) {
+	rows, err := ex.Query(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	raw, err := pgx.CollectOneRow(rows, pgx.RowTo[[]byte])
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	var results []explainResult
+	if err := json.Unmarshal(raw, &results); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	var b strings.Builder
+	for _, r := range results {
+		p := planPrinter{out: &b, color: color, total: r.ExecutionTime}
+		p.node(r.Plan, "", "")
+		fmt.Fprintf(&b, "Planning: %.3f ms  Execution: %s\n", r.PlanningTime, p.bold(fmt.Sprintf("%.3f ms", r.ExecutionTime)))
+	}
+	if _, err := io.WriteString(out, b.String()); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return rows.CommandTag(), nil
+}
+
+type planPrinter struct {
+	out   *strings.Builder
+	color bool
+	total float64
+}
+
+func (p *planPrinter) paint(colors text.Colors, s string) string {
+	if !p.color {
+		return s
+	}
+	return colors.Sprint(s)
+}
+
+func (p *planPrinter) bold(s string) string {
+	return p.paint(text.Colors{text.Bold}, s)
+}
+
+// node prints a plan node and its children. prefix is written in front of
+// the node itself, indent in front of its details and children.
+func (p *planPrinter) node(n planNode, prefix, indent string) {
+	p.out.WriteString(prefix + p.bold(planLabel(n)) + "  " + p.stats(n) + "\n")
+	details := indent
+	if len(n.Plans) > 0 {
+		details += "│ "
+	} else {
+		details += "  "
+	}
+	for _, d := range planDetails(n) {
+		p.out.WriteString(details + "  " + p.paint(text.Colors{text.Faint}, d) + "\n")
+	}
+	if buffers := p.buffers(n); buffers != "" {
+		p.out.WriteString(details + "  " + buffers + "\n")
+	}
+	for i, child := range n.Plans {
+		if i == len(n.Plans)-1 {
+			p.node(child, indent+"└─ ", indent+"   ")
+		} else {
+			p.node(child, indent+"├─ ", indent+"│  ")
+		}
+	}
+}
+
+func planLabel(n planNode) string {
+	label := n.NodeType
+	if n.JoinType != "" && n.JoinType != "Inner" {
+		label = strings.TrimSuffix(label, " Join") + " " + n.JoinType + " Join"
+	}
+	if n.IndexName != "" {
+		label += " using " + n.IndexName
+	}
+	if n.RelationName != "" {
+		label += " on " + n.RelationName
+		if n.Alias != "" && n.Alias != n.RelationName {
+			label += " " + n.Alias
+		}
+	}
+	if n.CTEName != "" {
+		label += " on " + n.CTEName
+	}
+	return label
+}
+
+// stats formats the timing and rows of a node. The time is the node's own
+// time, without the time spent in its children.
+func (p *planPrinter) stats(n planNode) string {
+	if n.ActualLoops == 0 {
+		return p.paint(text.Colors{text.Faint}, "(never executed)")
+	}
+	self := n.ActualTime * n.ActualLoops
+	for _, c := range n.Plans {
+		self -= c.ActualTime * c.ActualLoops
+	}
+	self = max(self, 0)
+	timing := fmt.Sprintf("%.3f ms", self)
+	if p.total > 0 {
+		share := self / p.total * 100
+		timing += fmt.Sprintf(" %.0f%%", share)
+		switch {
+		case share >= 50:
+			timing = p.paint(text.Colors{text.FgRed, text.Bold}, timing)
+		case share >= 10:
+			timing = p.paint(text.Colors{text.FgYellow}, timing)
+		}
+	}
+
+	rows := fmt.Sprintf("rows=%.0f", n.ActualRows)
+	if n.ActualLoops > 1 {
+		rows += fmt.Sprintf(" loops=%.0f", n.ActualLoops)
+	}
+	estimate := fmt.Sprintf("est=%.0f", n.PlanRows)
+	if off := estimateFactor(n.PlanRows, n.ActualRows); off >= 10 {
+		estimate = p.paint(text.Colors{text.FgYellow}, fmt.Sprintf("%s (%.0fx off)", estimate, off))
+	}
+	return "(" + timing + ", " + rows + ", " + estimate + ")"
+}
+
+// estimateFactor returns how many times the row estimate is off.
+func estimateFactor(planned, actual float64) float64 {
+	planned, actual = max(planned, 1), max(actual, 1)
+	return max(planned/actual, actual/planned)
+}
+
+func planDetails(n planNode) []string {
+	var details []string
+	for _, c := range []struct{ name, value string }{
+		{"Index Cond", n.IndexCond},
+		{"Hash Cond", n.HashCond},
+		{"Merge Cond", n.MergeCond},
+		{"Join Filter", n.JoinFilter},
+		{"Filter", n.Filter},
+	} {
+		if c.value != "" {
+			details = append(details, c.name+": "+c.value)
+		}
+	}
+	if n.RowsRemovedByFilter > 0 {
+		details = append(details, fmt.Sprintf("Rows Removed by Filter: %.0f", n.RowsRemovedByFilter))
+	}
+	return details
+}
+
+// buffers formats the buffer usage of a node, blocks that had to be read
+// from disk or temp files are highlighted.
+func (p *planPrinter) buffers(n planNode) string {
+	var parts []string
+	add := func(name string, blocks int64, colors text.Colors) {
+		if blocks > 0 {
+			parts = append(parts, p.paint(colors, fmt.Sprintf("%s=%d", name, blocks)))
+		}
+	}
+	add("hit", n.SharedHitBlocks, nil)
+	add("read", n.SharedReadBlocks, text.Colors{text.FgYellow})
+	add("dirtied", n.SharedDirtied, nil)
+	add("written", n.SharedWritten, nil)
+	add("temp read", n.TempReadBlocks, text.Colors{text.FgYellow})
+	add("temp written", n.TempWrittenBlocks, text.Colors{text.FgYellow})
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Buffers: " + strings.Join(parts, " ")
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestExplainStatements(t *testing.T) {
+	statements := []sqlStatement{{sql: "SET search_path = app"}, {sql: "SELECT * FROM t"}}
+	got, err := explainStatements(statements, "text", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].sql != "SET search_path = app" || got[1].sql != "EXPLAIN (FORMAT TEXT) SELECT * FROM t" {
+		t.Errorf("explainStatements = %v", got)
+	}
+}
+
+func TestExplainStatementsRejectsDDL(t *testing.T) {
+	for _, sql := range []string{"DROP TABLE t", "TRUNCATE t", "ALTER TABLE t ADD c int", "COPY t FROM STDIN", "COMMIT"} {
+		statements := []sqlStatement{{sql: "SELECT 1"}, {sql: sql}}
+		if _, err := explainStatements(statements, "text", false); err == nil {
+			t.Errorf("--explain of %q succeeded, want an error instead of executing it", sql)
+		}
+	}
+	// --analyze runs them and rolls them back.
+	if _, err := explainStatements([]sqlStatement{{sql: "DROP TABLE t"}}, "", true); err != nil {
+		t.Errorf("--analyze of DROP TABLE: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsUpload uploads to a Google Cloud Storage object with the application
+// default credentials. The writer sends the output in chunks as it is
+// produced.
+func gcsUpload(bucket, key string) (func(context.Context, io.Reader) error, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, r io.Reader) error {
+		// Canceling the context before Close aborts the upload.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		w := client.Bucket(bucket).Object(key).NewWriter(ctx)
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		return w.Close()
+	}, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/urfave/cli/v2"
+)
+
+func genCommand(args *connArgs) *cli.Command {
+	var structName string
+	return &cli.Command{
+		Name:  "gen",
+		Usage: "Generate code from the result description of a query",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "go",
+				Usage:     "Print a Go struct matching the columns of a query",
+				UsageText: "pgexec gen go --name User \"SELECT * FROM users;\"",
+				Flags: append(connFlags(args),
+					&cli.StringFlag{
+						Name:        "name",
+						Destination: &structName,
+						Usage:       "Struct name, derived from the queried table if omitted",
+					},
+				),
+				Action: func(cCtx *cli.Context) error {
+					if err := applyProfile(cCtx, args.profile); err != nil {
+						return err
+					}
+					if err := loadEnvFile(args); err != nil {
+						return err
+					}
+					return genGoCommand(cCtx.Context, *args, structName, cCtx.Args().Get(0))
+				},
+			},
+		},
+	}
+}
+
+// genGoCommand prepares the query without executing it and prints a struct
+// usable with pgx.RowToStructByName.
+func genGoCommand(ctx context.Context, connArgs connArgs, structName string, sql string) error {
+	if trim(sql) == "" {
+		return errors.New("no query given")
+	}
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	sd, err := conn.Conn().Prepare(ctx, "", sql)
+	if err != nil {
+		return err
+	}
+	notNull, err := notNullColumns(ctx, conn.Conn().PgConn(), sd.Fields)
+	if err != nil {
+		return err
+	}
+
+	if structName == "" {
+		structName = goIdentifier(tableBaseName(inferTable(sql)))
+		if structName == "" {
+			structName = "Row"
+		}
+	}
+	src, err := genGoStruct(structName, sd.Fields, notNull)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(src)
+	return err
+}
+
+type columnRef struct {
+	table uint32
+	attr  uint16
+}
+
+// notNullColumns looks up which result columns come straight from a table
+// column declared NOT NULL.
+func notNullColumns(ctx context.Context, conn *pgconn.PgConn, fields []pgconn.FieldDescription) (map[columnRef]bool, error) {
+	var tables, attrs []string
+	for _, f := range fields {
+		if f.TableOID != 0 && f.TableAttributeNumber > 0 {
+			tables = append(tables, strconv.FormatUint(uint64(f.TableOID), 10))
+			attrs = append(attrs, strconv.FormatUint(uint64(f.TableAttributeNumber), 10))
+		}
+	}
+	notNull := map[columnRef]bool{}
+	if len(tables) == 0 {
+		return notNull, nil
+	}
+
+	res := conn.ExecParams(ctx, `SELECT attrelid, attnum FROM pg_attribute
+		WHERE attnotnull AND (attrelid, attnum) IN (SELECT * FROM unnest($1::oid[], $2::int2[]))`,
+		[][]byte{[]byte("{" + strings.Join(tables, ",") + "}"), []byte("{" + strings.Join(attrs, ",") + "}")},
+		nil, nil, nil).Read()
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	for _, row := range res.Rows {
+		table, err := strconv.ParseUint(string(row[0]), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := strconv.ParseUint(string(row[1]), 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		notNull[columnRef{uint32(table), uint16(attr)}] = true
+	}
+	return notNull, nil
+}
+
+func genGoStruct(name string, fields []pgconn.FieldDescription, notNull map[columnRef]bool) ([]byte, error) {
+	imports := map[string]bool{}
+	var body strings.Builder
+	seen := map[string]bool{}
+	for _, f := range fields {
+		fieldName := goIdentifier(f.Name)
+		if fieldName == "" {
+			fieldName = "Column"
+		}
+		unique := fieldName
+		for n := 2; seen[unique]; n++ {
+			unique = fieldName + strconv.Itoa(n)
+		}
+		seen[unique] = true
+
+		typ, pkg, nullable := goType(f.DataTypeOID)
+		if pkg != "" {
+			imports[pkg] = true
+		}
+		if !nullable && !notNull[columnRef{f.TableOID, f.TableAttributeNumber}] {
+			typ = "*" + typ
+		}
+		fmt.Fprintf(&body, "\t%s %s `db:%s`\n", unique, typ, strconv.Quote(f.Name))
+	}
+
+	var src strings.Builder
+	if len(imports) > 0 {
+		pkgs := make([]string, 0, len(imports))
+		for pkg := range imports {
+			pkgs = append(pkgs, strconv.Quote(pkg))
+		}
+		sort.Strings(pkgs)
+		fmt.Fprintf(&src, "import (\n%s\n)\n\n", strings.Join(pkgs, "\n"))
+	}
+	fmt.Fprintf(&src, "type %s struct {\n%s}\n", name, body.String())
+	return format.Source([]byte(src.String()))
+}
+
+// goType returns the Go type pgx scans a column of the given type into, the
+// package it needs and whether the type can represent NULL on its own.
+func goType(oid uint32) (typ string, pkg string, nullable bool) {
+	const pgtypePkg = "github.com/jackc/pgx/v5/pgtype"
+	switch oid {
+	case pgtype.BoolOID:
+		return "bool", "", false
+	case pgtype.Int2OID:
+		return "int16", "", false
+	case pgtype.Int4OID, pgtype.OIDOID:
+		return "int32", "", false
+	case pgtype.Int8OID:
+		return "int64", "", false
+	case pgtype.Float4OID:
+		return "float32", "", false
+	case pgtype.Float8OID:
+		return "float64", "", false
+	case pgtype.TextOID, pgtype.VarcharOID, pgtype.BPCharOID, pgtype.NameOID:
+		return "string", "", false
+	case pgtype.ByteaOID:
+		return "[]byte", "", true
+	case pgtype.NumericOID:
+		return "pgtype.Numeric", pgtypePkg, true
+	case pgtype.DateOID, pgtype.TimestampOID, pgtype.TimestamptzOID:
+		return "time.Time", "time", false
+	case pgtype.TimeOID:
+		return "pgtype.Time", pgtypePkg, true
+	case pgtype.IntervalOID:
+		return "pgtype.Interval", pgtypePkg, true
+	case pgtype.UUIDOID:
+		return "pgtype.UUID", pgtypePkg, true
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		return "json.RawMessage", "encoding/json", true
+	case pgtype.InetOID, pgtype.CIDROID:
+		return "netip.Prefix", "net/netip", false
+	case pgtype.BoolArrayOID:
+		return "[]bool", "", true
+	case pgtype.Int2ArrayOID:
+		return "[]int16", "", true
+	case pgtype.Int4ArrayOID:
+		return "[]int32", "", true
+	case pgtype.Int8ArrayOID:
+		return "[]int64", "", true
+	case pgtype.Float4ArrayOID:
+		return "[]float32", "", true
+	case pgtype.Float8ArrayOID:
+		return "[]float64", "", true
+	case pgtype.TextArrayOID, pgtype.VarcharArrayOID:
+		return "[]string", "", true
+	}
+	return "string", "", false
+}
+
+var goInitialisms = map[string]string{
+	"id": "ID", "ids": "IDs", "url": "URL", "uri": "URI", "uuid": "UUID", "json": "JSON",
+	"sql": "SQL", "api": "API", "http": "HTTP", "ip": "IP", "html": "HTML", "xml": "XML",
+}
+
+// goIdentifier turns a column or table name like user_id into UserID.
+func goIdentifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if initialism, ok := goInitialisms[strings.ToLower(w)]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(strings.ToUpper(string(r[0])) + string(r[1:]))
+	}
+	ident := b.String()
+	if ident != "" && unicode.IsDigit([]rune(ident)[0]) {
+		ident = "Col" + ident
+	}
+	return ident
+}
+
+var identPattern = regexp.MustCompile(`"(?:[^"]|"")+"|[^."\s]+`)
+
+// tableBaseName returns the unquoted, unqualified name of a table
+// reference like public."Users".
+func tableBaseName(name string) string {
+	parts := identPattern.FindAllString(name, -1)
+	if len(parts) == 0 {
+		return ""
+	}
+	last := parts[len(parts)-1]
+	if strings.HasPrefix(last, `"`) {
+		return strings.ReplaceAll(last[1:len(last)-1], `""`, `"`)
+	}
+	return last
+}
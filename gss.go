@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/otan/gopgkrb5"
+)
+
+// init registers the Kerberos provider for GSSAPI authentication, which
+// uses the ticket cache of kinit ($KRB5CCNAME) and /etc/krb5.conf
+// ($KRB5_CONFIG).
+func init() {
+	pgconn.RegisterGSSProvider(func() (pgconn.GSS, error) { return gopgkrb5.NewGSS() })
+}
+
+// gssEncMode checks --gssencmode, or gssencmode from the connection string
+// or $PGGSSENCMODE. pgx authenticates with GSSAPI but can't encrypt the
+// connection with it, so only disable and prefer are accepted, the latter
+// falling back to TLS or plaintext like libpq does without a ticket.
+func gssEncMode(config *pgxpool.Config, connArgs connArgs) error {
+	params := config.ConnConfig.RuntimeParams
+	mode := params["gssencmode"]
+	// Not a server setting, it would be rejected as unknown on connect.
+	delete(params, "gssencmode")
+	if connArgs.gssEncMode != "" {
+		mode = connArgs.gssEncMode
+	}
+	if mode == "" {
+		mode = os.Getenv("PGGSSENCMODE")
+	}
+	switch mode {
+	case "", "disable", "prefer":
+		return nil
+	case "require":
+		return errors.New("gssencmode require is not supported, GSSAPI is used for authentication only; use --sslmode to encrypt the connection")
+	default:
+		return fmt.Errorf("unknown gssencmode %q", mode)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var (
+	keywordColor    = text.Colors{text.FgBlue, text.Bold}
+	stringColor     = text.Colors{text.FgGreen}
+	identifierColor = text.Colors{text.FgCyan}
+	numberColor     = text.Colors{text.FgMagenta}
+	commentColor    = text.Colors{text.Faint}
+	metaColor       = text.Colors{text.FgYellow}
+)
+
+var keywordSet = func() map[string]bool {
+	set := make(map[string]bool, len(sqlKeywords))
+	for _, kw := range sqlKeywords {
+		set[kw] = true
+	}
+	return set
+}()
+
+// highlightSQL colors the keywords, literals, quoted identifiers and
+// comments of sql from the byte offset from on. The text before it is only
+// scanned for context, e.g. the previous lines of a statement that started
+// a string.
+func highlightSQL(sql string, from int) string {
+	if rest := trim(sql[from:]); strings.HasPrefix(rest, `\`) && trim(sql[:from]) == "" {
+		return metaColor.Sprint(sql[from:])
+	}
+	var b strings.Builder
+	pos := 0
+	for _, seg := range scanSQL(sql) {
+		end := pos + len(seg.text)
+		if end <= from {
+			pos = end
+			continue
+		}
+		s := seg.text[max(from-pos, 0):]
+		pos = end
+		switch {
+		case seg.kind == commentSegment:
+			b.WriteString(commentColor.Sprint(s))
+		case seg.kind == quotedSegment && strings.HasPrefix(seg.text, `"`):
+			b.WriteString(identifierColor.Sprint(s))
+		case seg.kind == quotedSegment:
+			b.WriteString(stringColor.Sprint(s))
+		default:
+			highlightCode(&b, s)
+		}
+	}
+	return b.String()
+}
+
+// highlightCode colors the keywords and numbers of a code segment.
+func highlightCode(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		if !isIdentByte(s[i]) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && (isIdentByte(s[j]) || s[j] == '.' && s[i] >= '0' && s[i] <= '9') {
+			j++
+		}
+		word := s[i:j]
+		switch {
+		case word[0] >= '0' && word[0] <= '9':
+			b.WriteString(numberColor.Sprint(word))
+		case keywordSet[strings.ToUpper(word)]:
+			b.WriteString(keywordColor.Sprint(word))
+		default:
+			b.WriteString(word)
+		}
+		i = j
+	}
+}
+
+// sqlPainter highlights the REPL input as it is typed.
+type sqlPainter struct {
+	r *repl
+}
+
+func (p sqlPainter) Paint(line []rune, _ int) []rune {
+	prefix := p.r.buf.String()
+	return []rune(highlightSQL(prefix+string(line), len(prefix)))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// historyLimit is the number of entries kept in the history file.
+const historyLimit = 1000
+
+// historyFile returns the path of the REPL history, ~/.pgexec_history
+// unless PGEXEC_HISTORY is set.
+func historyFile() string {
+	if path := os.Getenv("PGEXEC_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pgexec_history")
+}
+
+// compactHistory removes duplicate entries from the history file, keeping
+// the most recent one, so repeated queries don't crowd out older ones.
+func compactHistory(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	seen := map[string]bool{}
+	var entries []string
+	for i := len(lines) - 1; i >= 0 && len(entries) < historyLimit; i-- {
+		if lines[i] == "" || seen[lines[i]] {
+			continue
+		}
+		seen[lines[i]] = true
+		entries = append(entries, lines[i])
+	}
+	if len(entries) == len(lines) {
+		return nil
+	}
+	slices.Reverse(entries)
+	return os.WriteFile(path, []byte(strings.Join(entries, "\n")+"\n"), 0o600)
+}
+
+// historyEscaper keeps a multi-line input on one line of the history
+// file, the history file holds one entry per line.
+var historyEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// historyEntry turns an input into a single history line.
+func historyEntry(input string) string {
+	return historyEscaper.Replace(trim(input))
+}
+
+// historyInput restores the input of a history line. Unknown escapes are
+// kept as they are, so entries written before escaping was added, like
+// \dt, load unchanged.
+func historyInput(entry string) string {
+	var b strings.Builder
+	for i := 0; i < len(entry); i++ {
+		if entry[i] != '\\' || i+1 == len(entry) {
+			b.WriteByte(entry[i])
+			continue
+		}
+		switch entry[i+1] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(entry[i])
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
+
+// loadHistory returns the inputs of the history file, oldest first.
+func loadHistory(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			inputs = append(inputs, historyInput(line))
+		}
+	}
+	return inputs, nil
+}
+
+// appendHistory adds an entry to the end of the history file.
+func appendHistory(path, entry string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(entry + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
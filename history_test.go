@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestHistoryEntry(t *testing.T) {
+	inputs := []string{
+		"SELECT 1 -- note\nFROM t;",
+		"SELECT $$a\nb$$;",
+		`SELECT E'a\nb', '\\';`,
+		`\dt`,
+	}
+	for _, input := range inputs {
+		entry := historyEntry(input)
+		if got := historyInput(entry); got != input {
+			t.Errorf("historyInput(%q) = %q, want %q", entry, got, input)
+		}
+	}
+	if got := historyInput(`\dt public.*`); got != `\dt public.*` {
+		t.Errorf("historyInput of an unescaped entry = %q", got)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/urfave/cli/v2"
+)
+
+// importArgs holds the flags of the import command.
+type importArgs struct {
+	table     string
+	file      string
+	format    string
+	delimiter string
+	null      string
+	// extraColumn takes the keys of JSON records that aren't columns.
+	extraColumn string
+}
+
+// importExtensions map file extensions to import formats.
+var importExtensions = map[string]string{
+	".csv":    "csv",
+	".json":   "json",
+	".ndjson": "ndjson",
+	".jsonl":  "ndjson",
+}
+
+// importFormat returns the --format of the import command, defaulting to
+// the extension of the file or CSV.
+func importFormat(inArgs importArgs) (string, error) {
+	format := inArgs.format
+	if format == "" {
+		format = importExtensions[strings.ToLower(filepath.Ext(inArgs.file))]
+	}
+	switch format {
+	case "":
+		return "csv", nil
+	case "csv", "json", "ndjson":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown import format %q", format)
+}
+
+func importCommand(args *connArgs) *cli.Command {
+	var inArgs importArgs
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Load a local CSV, JSON or NDJSON file into a table",
+		UsageText: "pgexec import --url \"postgres://...\" --table users --file users.csv",
+		Flags: append(connFlags(args),
+			&cli.StringFlag{
+				Name:        "table",
+				Destination: &inArgs.table,
+				Required:    true,
+				Usage:       "Table to load, optionally schema qualified",
+			},
+			&cli.StringFlag{
+				Name:        "file",
+				Aliases:     []string{"f"},
+				Value:       "-",
+				Destination: &inArgs.file,
+				Usage:       "File to load, - reads stdin",
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Destination: &inArgs.format,
+				Usage:       "File format (csv, json, ndjson), defaults to the file extension or csv",
+			},
+			&cli.StringFlag{
+				Name:        "delimiter",
+				Destination: &inArgs.delimiter,
+				Usage:       "Field delimiter of CSV files, e.g. ';', '|' or '\\t'",
+			},
+			&cli.StringFlag{
+				Name:        "null",
+				Destination: &inArgs.null,
+				Usage:       "Unquoted string that stands for NULL in CSV files, empty fields by default",
+			},
+			&cli.StringFlag{
+				Name:        "extra-column",
+				Destination: &inArgs.extraColumn,
+				Usage:       "jsonb column that takes the keys of JSON records without a column of their own",
+			},
+		),
+		Action: func(cCtx *cli.Context) error {
+			if err := applyProfile(cCtx, args.profile); err != nil {
+				return err
+			}
+			if err := loadEnvFile(args); err != nil {
+				return err
+			}
+			return runImport(cCtx.Context, *args, inArgs)
+		},
+	}
+}
+
+// runImport loads a file with the binary COPY protocol, like \copy in psql
+// but with the values checked and converted on the client, so errors
+// point to the line of the file.
+func runImport(ctx context.Context, connArgs connArgs, inArgs importArgs) error {
+	format, err := importFormat(inArgs)
+	if err != nil {
+		return err
+	}
+	in := os.Stdin
+	if inArgs.file != "-" {
+		f, err := os.Open(inArgs.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	table := pgx.Identifier(strings.Split(inArgs.table, "."))
+	var src pgx.CopyFromSource
+	var columns []string
+	if format == "csv" {
+		src, columns, err = newCSVSource(ctx, conn.Conn(), table, in, inArgs)
+	} else {
+		src, columns, err = newJSONSource(ctx, conn.Conn(), table, in, format == "json", inArgs.extraColumn)
+	}
+	if err != nil {
+		return err
+	}
+	n, err := conn.Conn().CopyFrom(ctx, table, columns, src)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "COPY %d\n", n)
+	return nil
+}
+
+// tableColumns returns the names and type OIDs of the given columns of a
+// table, or of all of them when none are given. It fails for columns that
+// don't exist.
+func tableColumns(ctx context.Context, conn *pgx.Conn, table pgx.Identifier, columns []string) ([]string, []uint32, error) {
+	list := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, col := range columns {
+			quoted[i] = pgx.Identifier{col}.Sanitize()
+		}
+		list = strings.Join(quoted, ", ")
+	}
+	sd, err := conn.Prepare(ctx, "", fmt.Sprintf("SELECT %s FROM %s", list, table.Sanitize()))
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, len(sd.Fields))
+	types := make([]uint32, len(sd.Fields))
+	for i, field := range sd.Fields {
+		names[i] = field.Name
+		types[i] = field.DataTypeOID
+	}
+	return names, types, nil
+}
+
+// parseText converts a value in its text representation to the Go value
+// of the column type, as the binary COPY format needs those. Values of
+// types pgx doesn't know, like enums, are sent as text.
+func parseText(typeMap *pgtype.Map, oid uint32, s string) (any, error) {
+	typ, ok := typeMap.TypeForOID(oid)
+	if !ok {
+		return s, nil
+	}
+	return typ.Codec.DecodeValue(typeMap, oid, pgtype.TextFormatCode, []byte(s))
+}
+
+// newCSVSource reads the header of a CSV file, which names the columns.
+func newCSVSource(ctx context.Context, conn *pgx.Conn, table pgx.Identifier, in io.Reader, inArgs importArgs) (*csvSource, []string, error) {
+	delim, err := parseDelimiter(inArgs.delimiter, ',')
+	if err != nil {
+		return nil, nil, err
+	}
+	r := newCSVReader(in, delim)
+	header, _, _, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("the file has no header")
+		}
+		return nil, nil, err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+	}
+	_, types, err := tableColumns(ctx, conn, table, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &csvSource{reader: r, columns: columns, types: types, typeMap: conn.TypeMap(), null: inArgs.null}, columns, nil
+}
+
+// csvSource feeds the records of a CSV file to CopyFrom.
+type csvSource struct {
+	reader  *csvReader
+	columns []string
+	types   []uint32
+	typeMap *pgtype.Map
+	null    string
+
+	values []any
+	err    error
+}
+
+func (s *csvSource) Next() bool {
+	record, quoted, line, err := s.reader.Read()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.err = err
+		}
+		return false
+	}
+	s.values = make([]any, len(record))
+	for i, field := range record {
+		if field == s.null && !quoted[i] {
+			continue
+		}
+		if s.values[i], err = parseText(s.typeMap, s.types[i], field); err != nil {
+			s.err = fmt.Errorf("line %d, column %s: %w", line, s.columns[i], err)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *csvSource) Values() ([]any, error) {
+	return s.values, nil
+}
+
+func (s *csvSource) Err() error {
+	return s.err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestCSVSourceNulls(t *testing.T) {
+	tests := []struct {
+		null string
+		in   string
+		want [][]any
+	}{
+		// Like COPY, only unquoted fields matching the marker are NULL.
+		{"", "1,\n2,\"\"\n", [][]any{{int32(1), nil}, {int32(2), ""}}},
+		{`\N`, "\\N,\\N\n3,\"\\N\"\n4,\n", [][]any{{nil, nil}, {int32(3), `\N`}, {int32(4), ""}}},
+	}
+	for _, tt := range tests {
+		s := &csvSource{
+			reader:  newCSVReader(strings.NewReader(tt.in), ','),
+			columns: []string{"id", "name"},
+			types:   []uint32{pgtype.Int4OID, pgtype.TextOID},
+			typeMap: pgtype.NewMap(),
+			null:    tt.null,
+		}
+		var got [][]any
+		for s.Next() {
+			values, _ := s.Values()
+			got = append(got, values)
+		}
+		if err := s.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("null %q, records of %q = %v, want %v", tt.null, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCSVSourceReportsLine(t *testing.T) {
+	s := &csvSource{
+		reader:  newCSVReader(strings.NewReader("1\n\"multi\nline\"\n"), ','),
+		columns: []string{"id"},
+		types:   []uint32{pgtype.Int4OID},
+		typeMap: pgtype.NewMap(),
+	}
+	for s.Next() {
+	}
+	if err := s.Err(); err == nil || !strings.HasPrefix(err.Error(), "line 2, column id:") {
+		t.Errorf("Err() = %v, want an error for line 2", err)
+	}
+}
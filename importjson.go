@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// jsonRecord is an object of a JSON or NDJSON file.
+type jsonRecord map[string]json.RawMessage
+
+// jsonSource feeds the objects of a JSON array or an NDJSON file to
+// CopyFrom. The columns are those of the table that are keys of any
+// object, objects without some of them leave them NULL. Files are read
+// twice for that, on stdin the first object decides the columns.
+type jsonSource struct {
+	dec     *json.Decoder
+	array   bool
+	record  int
+	pending jsonRecord
+
+	columns []string
+	types   []uint32
+	index   map[string]int
+	// tableColumns are all columns of the table, for the error about keys
+	// that aren't loaded.
+	tableColumns map[string]bool
+	// extra is the index of the --extra-column, -1 without one.
+	extra   int
+	typeMap *pgtype.Map
+
+	values []any
+	err    error
+}
+
+func newJSONSource(ctx context.Context, conn *pgx.Conn, table pgx.Identifier, in *os.File, array bool, extraColumn string) (*jsonSource, []string, error) {
+	names, types, err := tableColumns(ctx, conn, table, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &jsonSource{array: array, index: map[string]int{}, tableColumns: map[string]bool{}, extra: -1, typeMap: conn.TypeMap()}
+	for _, name := range names {
+		s.tableColumns[name] = true
+	}
+	keys, err := s.scanKeys(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, name := range names {
+		if keys[name] || name == extraColumn {
+			if name == extraColumn {
+				s.extra = len(s.columns)
+			}
+			s.index[name] = len(s.columns)
+			s.columns = append(s.columns, name)
+			s.types = append(s.types, types[i])
+		}
+	}
+	if extraColumn != "" && s.extra < 0 {
+		return nil, nil, fmt.Errorf("table %s has no column %s", table.Sanitize(), extraColumn)
+	}
+	return s, s.columns, nil
+}
+
+// scanKeys returns the keys of all objects of a file and rewinds it. On
+// stdin, which can only be read once, only the first object is looked at.
+func (s *jsonSource) scanKeys(in *os.File) (map[string]bool, error) {
+	info, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	seekable := info.Mode().IsRegular()
+	var first jsonRecord
+	keys := map[string]bool{}
+	if err := s.start(in); err != nil {
+		return nil, err
+	}
+	for {
+		record, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+		if first == nil {
+			first = record
+		}
+		for key := range record {
+			keys[key] = true
+		}
+		if !seekable {
+			s.pending = first
+			return keys, nil
+		}
+	}
+	if first == nil {
+		return nil, errors.New("the file has no records")
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	s.record = 0
+	return keys, s.start(in)
+}
+
+// start begins decoding a file, after the opening bracket of an array.
+func (s *jsonSource) start(in io.Reader) error {
+	s.dec = json.NewDecoder(in)
+	if s.array {
+		if tok, err := s.dec.Token(); err != nil || tok != json.Delim('[') {
+			return errors.New("the file is not a JSON array")
+		}
+	}
+	return nil
+}
+
+// next decodes the next object, it returns nil at the end of the file.
+func (s *jsonSource) next() (jsonRecord, error) {
+	if s.array && !s.dec.More() {
+		return nil, nil
+	}
+	s.record++
+	var record jsonRecord
+	if err := s.dec.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("record %d: %w", s.record, err)
+	}
+	if record == nil {
+		return nil, fmt.Errorf("record %d: not an object", s.record)
+	}
+	return record, nil
+}
+
+func (s *jsonSource) Next() bool {
+	record := s.pending
+	s.pending = nil
+	if record == nil {
+		if record, s.err = s.next(); record == nil {
+			return false
+		}
+	}
+	s.values = make([]any, len(s.columns))
+	extra := jsonRecord{}
+	for key, raw := range record {
+		i, ok := s.index[key]
+		if !ok && s.tableColumns[key] {
+			s.err = fmt.Errorf("record %d: key %s isn't in the first record, which decides the columns on stdin, import from a file instead", s.record, key)
+			return false
+		}
+		if !ok || i == s.extra {
+			extra[key] = raw
+			continue
+		}
+		v, err := jsonValue(s.typeMap, s.types[i], raw)
+		if err != nil {
+			s.err = fmt.Errorf("record %d, key %s: %w", s.record, key, err)
+			return false
+		}
+		s.values[i] = v
+	}
+	if s.extra >= 0 {
+		b, err := json.Marshal(extra)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		s.values[s.extra] = string(b)
+	} else if len(extra) > 0 {
+		keys := make([]string, 0, len(extra))
+		for key := range extra {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		s.err = fmt.Errorf("record %d: no column for %s, collect such keys in a jsonb column with --extra-column", s.record, strings.Join(keys, ", "))
+		return false
+	}
+	return true
+}
+
+func (s *jsonSource) Values() ([]any, error) {
+	return s.values, nil
+}
+
+func (s *jsonSource) Err() error {
+	return s.err
+}
+
+// jsonValue converts a JSON value to the Go value of the column type.
+// json and jsonb columns take it as is, strings are parsed like CSV
+// fields and arrays become array literals for array columns.
+func jsonValue(typeMap *pgtype.Map, oid uint32, raw json.RawMessage) (any, error) {
+	raw = bytes.TrimSpace(raw)
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	if oid == pgtype.JSONOID || oid == pgtype.JSONBOID {
+		return string(raw), nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		return parseText(typeMap, oid, s)
+	case '[':
+		if typ, ok := typeMap.TypeForOID(oid); ok {
+			if _, ok := typ.Codec.(*pgtype.ArrayCodec); ok {
+				literal, err := arrayLiteral(raw)
+				if err != nil {
+					return nil, err
+				}
+				return parseText(typeMap, oid, literal)
+			}
+		}
+	}
+	return parseText(typeMap, oid, string(raw))
+}
+
+// arrayLiteral turns a JSON array into a Postgres array literal.
+func arrayLiteral(raw json.RawMessage) (string, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, elem := range elems {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		elem = bytes.TrimSpace(elem)
+		switch {
+		case string(elem) == "null":
+			b.WriteString("NULL")
+		case elem[0] == '[':
+			nested, err := arrayLiteral(elem)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(nested)
+		case elem[0] == '"':
+			var s string
+			if err := json.Unmarshal(elem, &s); err != nil {
+				return "", err
+			}
+			b.WriteString(quoteArrayElement(s))
+		default:
+			b.WriteString(quoteArrayElement(string(elem)))
+		}
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// inputArgs holds the flags that select where the SQL is read from.
+type inputArgs struct {
+	sources   []sqlSource
+	params    []string
+	variables []string
+	envsubst  bool
+	copyInput string
+
+	templateSQL     bool
+	templateContext []string
+}
+
+// sqlSource is a single -c or -f flag.
+type sqlSource struct {
+	file  bool
+	value string
+}
+
+// sourceFlag collects -c and -f flags into one list, so they are executed
+// in the order they were given like in psql.
+type sourceFlag struct {
+	sources *[]sqlSource
+	file    bool
+}
+
+func (f *sourceFlag) Set(value string) error {
+	*f.sources = append(*f.sources, sqlSource{file: f.file, value: value})
+	return nil
+}
+
+func (f *sourceFlag) String() string {
+	return ""
+}
+
+// sqlScript is SQL read from one source. name is the file it was read
+// from, if any.
+type sqlScript struct {
+	name  string
+	sql   string
+	stdin bool
+}
+
+// readSQL returns the SQL passed as argument, or with -c and -f. Reading
+// from a file avoids the ARG_MAX limit for large scripts. Without any of
+// them, or with "-", the SQL is read from stdin.
+func readSQL(inArgs inputArgs, arg string) ([]sqlScript, error) {
+	sources := inArgs.sources
+	switch {
+	case len(sources) > 0 && arg != "":
+		return nil, errors.New("pass the SQL either as argument or with -c and --file, not both")
+	case len(sources) > 0:
+	case arg != "" && arg != "-":
+		sources = []sqlSource{{value: arg}}
+	case arg == "" && isTerminal(os.Stdin):
+		return nil, errors.New("no query given, pass it as argument, with -c, --file or on stdin")
+	default:
+		sources = []sqlSource{{file: true, value: "-"}}
+	}
+
+	var scripts []sqlScript
+	for _, src := range sources {
+		s, err := readSource(src)
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, s...)
+	}
+	return scripts, nil
+}
+
+func readSource(src sqlSource) ([]sqlScript, error) {
+	switch {
+	case !src.file:
+		return []sqlScript{{sql: src.value}}, nil
+	case src.value == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return []sqlScript{{sql: string(b), stdin: true}}, nil
+	}
+
+	files, err := sourceFiles(src.value)
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]sqlScript, len(files))
+	for i, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		scripts[i] = sqlScript{name: file, sql: string(b)}
+	}
+	return scripts, nil
+}
+
+// sourceFiles expands a --file argument. Directories stand for the .sql
+// files they contain and globs like 'migrations/*.sql' are expanded, both
+// in lexical order.
+func sourceFiles(path string) ([]string, error) {
+	pattern := path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		pattern = filepath.Join(path, "*.sql")
+	} else if !strings.ContainsAny(path, "*?[") {
+		return []string{path}, nil
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files match %s", pattern)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+func isJSONField(field pgconn.FieldDescription) bool {
+	return field.DataTypeOID == pgtype.JSONOID || field.DataTypeOID == pgtype.JSONBOID
+}
+
+// rawJSONValues replaces the decoded values of json and jsonb columns with
+// the JSON sent by the server. Decoding into maps would lose the key order.
+func rawJSONValues(fields []pgconn.FieldDescription, values []any, raw [][]byte) {
+	for i, f := range fields {
+		if !isJSONField(f) || values[i] == nil {
+			continue
+		}
+		b := raw[i]
+		if f.DataTypeOID == pgtype.JSONBOID && f.Format == pgtype.BinaryFormatCode && len(b) > 0 {
+			// Binary jsonb is prefixed with a format version byte.
+			b = b[1:]
+		}
+		values[i] = json.RawMessage(bytes.Clone(b))
+	}
+}
+
+func compactJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+func prettyJSON(raw json.RawMessage, color bool) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	if !color {
+		return buf.String()
+	}
+	return highlightJSON(buf.Bytes())
+}
+
+var (
+	jsonKeyColor     = text.Colors{text.FgBlue}
+	jsonStringColor  = text.Colors{text.FgGreen}
+	jsonNumberColor  = text.Colors{text.FgCyan}
+	jsonLiteralColor = text.Colors{text.FgMagenta}
+)
+
+// highlightJSON colors keys, strings, numbers and literals of valid,
+// indented JSON.
+func highlightJSON(src []byte) string {
+	var out bytes.Buffer
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j++
+			color := jsonStringColor
+			if k := bytes.IndexFunc(src[j:], func(r rune) bool { return r != ' ' }); k >= 0 && src[j+k] == ':' {
+				color = jsonKeyColor
+			}
+			out.WriteString(color.Sprint(string(src[i:j])))
+			i = j
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(src) && bytes.IndexByte([]byte("0123456789.eE+-"), src[j]) >= 0 {
+				j++
+			}
+			out.WriteString(jsonNumberColor.Sprint(string(src[i:j])))
+			i = j
+		case c == 't' || c == 'f' || c == 'n':
+			j := i + 1
+			for j < len(src) && src[j] >= 'a' && src[j] <= 'z' {
+				j++
+			}
+			out.WriteString(jsonLiteralColor.Sprint(string(src[i:j])))
+			i = j
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.String()
+}
@@ -2,19 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
-	"github.com/gofrs/uuid/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/urfave/cli/v2"
 )
 
@@ -25,184 +26,1148 @@ type connArgs struct {
 	password string
 	database string
 	url      string
+	urls     []string
+	service  string
+	profile  string
+	envFile  string
+	daemon   string
+	socket   string
 	noTx     bool
+
+	targetSessionAttrs string
+
+	sslMode     string
+	sslRootCert string
+	sslCert     string
+	sslKey      string
+	sslPassword string
+
+	tlsSkipVerify  bool
+	channelBinding string
+
+	krbSrvName string
+	krbSPN     string
+	gssEncMode string
+
+	promptPassword  bool
+	passwordCommand string
+	connectTimeout  time.Duration
+	connectRetries  int
+	connectBackoff  time.Duration
+
+	applicationName string
+	searchPath      string
+	settings        cli.StringSlice
+	role            string
+
+	wait        bool
+	waitTimeout time.Duration
+	waitQuery   string
+
+	ssh    string
+	sshKey string
+
+	awsIAMAuth  bool
+	awsRegion   string
+	azureADAuth bool
+
+	cloudSQLInstance  string
+	cloudSQLPrivateIP bool
+
+	txPerFile  bool
+	onError    string
+	savepoints bool
+	dryRun     bool
+	echo       bool
+	echoColor  bool
+
+	explain       bool
+	explainFormat string
+	analyze       bool
+	analyzeCommit bool
+
+	repeat int
+	warmup int
+
+	parallel          int
+	timeout           time.Duration
+	retrySerializable int
+
+	// maxConns overrides the pool size when set.
+	maxConns          int
+	singleConnection  bool
+	keepalive         time.Duration
+	healthCheckPeriod time.Duration
+	maxConnIdleTime   time.Duration
+	poolerCompat      bool
+	requireVersion    string
+
+	isolation  string
+	deferrable bool
+	readOnly   bool
+	txOptions  pgx.TxOptions
 }
 
 func main() {
 	args := connArgs{}
+	outArgs := outputArgs{}
+	inArgs := inputArgs{}
+	params := cli.StringSlice{}
+	variables := cli.StringSlice{}
+	templateContext := cli.StringSlice{}
 
 	app := &cli.App{
 		Name:      "pgexec",
-		UsageText: "pgexec --url \"postgres://...\" \"SELECT * FROM users;\"",
-		Flags: []cli.Flag{
+		UsageText: "pgexec --url \"postgres://...\" \"SELECT * FROM users;\"\npgexec --url \"postgres://...\" -f script.sql",
+
+		UseShortOptionHandling:    true,
+		DisableSliceFlagSeparator: true,
+		Flags: append(connFlags(&args),
+			&cli.BoolFlag{
+				Name:        "no-transaction",
+				Aliases:     []string{"no-tx"},
+				Destination: &args.noTx,
+				Usage:       "Run in autocommit mode without transaction, e.g. for CREATE INDEX CONCURRENTLY or VACUUM",
+			},
+			&cli.GenericFlag{
+				Name:    "command",
+				Aliases: []string{"c"},
+				Value:   &sourceFlag{sources: &inArgs.sources},
+				Usage:   "SQL to execute, can be repeated and mixed with --file",
+			},
+			&cli.GenericFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Value:   &sourceFlag{sources: &inArgs.sources, file: true},
+				Usage:   "Read the SQL from a file instead of the argument, - reads stdin, can be repeated",
+			},
+			&cli.StringFlag{
+				Name:        "input",
+				Destination: &inArgs.copyInput,
+				Usage:       "File COPY ... FROM STDIN loads instead of stdin",
+			},
+			&cli.StringSliceFlag{
+				Name:        "param",
+				Destination: &params,
+				Usage:       "Value bound to the next $n placeholder, or name=value and name:type=value bound to :name, can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:        "set",
+				Aliases:     []string{"v"},
+				Destination: &variables,
+				Usage:       "Set a psql style variable name=value referenced as :name, :'name' or :\"name\", can be repeated",
+			},
+			&cli.BoolFlag{
+				Name:        "envsubst",
+				Destination: &inArgs.envsubst,
+				Usage:       "Replace ${VAR} in the SQL with environment variables before executing it",
+			},
+			&cli.BoolFlag{
+				Name:        "template-sql",
+				Destination: &inArgs.templateSQL,
+				Usage:       "Run the SQL through Go text/template with the --set variables and --template-context files as data",
+			},
+			&cli.StringSliceFlag{
+				Name:        "template-context",
+				Destination: &templateContext,
+				Usage:       "JSON file with data for --template-sql, can be repeated",
+			},
+			&cli.BoolFlag{
+				Name:        "tx-per-file",
+				Destination: &args.txPerFile,
+				Usage:       "Commit after every --file instead of running all files in one transaction",
+			},
+			&cli.IntFlag{
+				Name:        "parallel",
+				Destination: &args.parallel,
+				Usage:       "Run up to N files concurrently, each in its own transaction",
+			},
+			&cli.DurationFlag{
+				Name:        "timeout",
+				Destination: &args.timeout,
+				Usage:       "Cancel statements running longer than this, e.g. 30s, and exit with code 3",
+			},
+			&cli.StringFlag{
+				Name:        "isolation",
+				Destination: &args.isolation,
+				Usage:       "Isolation level of the transaction: read-committed, repeatable-read or serializable",
+			},
+			&cli.BoolFlag{
+				Name:        "deferrable",
+				Destination: &args.deferrable,
+				Usage:       "Start the transaction as DEFERRABLE, only has an effect for serializable read only transactions",
+			},
+			&cli.BoolFlag{
+				Name:        "read-only",
+				Destination: &args.readOnly,
+				Usage:       "Start the transaction as READ ONLY so the server rejects any writes",
+			},
+			&cli.IntFlag{
+				Name:        "retry-serializable",
+				Destination: &args.retrySerializable,
+				Usage:       "Retry the transaction up to N times on serialization failures and deadlocks",
+			},
+			&cli.StringFlag{
+				Name:        "on-error",
+				Value:       "rollback",
+				Destination: &args.onError,
+				Usage:       "What a failing statement does: rollback everything, stop and keep the statements before it, or continue with a warning",
+			},
+			&cli.BoolFlag{
+				Name:        "savepoints",
+				Destination: &args.savepoints,
+				Usage:       "Run every statement in a savepoint and continue after failures, same as --on-error continue",
+			},
+			&cli.BoolFlag{
+				Name:        "echo",
+				Aliases:     []string{"e"},
+				Destination: &args.echo,
+				Usage:       "Print every statement to stderr before executing it, highlighted on a terminal",
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Destination: &args.dryRun,
+				Usage:       "Only prepare the statements to check them against the database, without executing them",
+			},
+			&cli.BoolFlag{
+				Name:        "explain",
+				Destination: &args.explain,
+				Usage:       "Print the query plan instead of running the query",
+			},
+			&cli.BoolFlag{
+				Name:        "analyze",
+				Destination: &args.analyze,
+				Usage:       "Run the queries with EXPLAIN ANALYZE and print the plan as annotated tree, rolling back their writes",
+			},
+			&cli.BoolFlag{
+				Name:        "analyze-commit",
+				Destination: &args.analyzeCommit,
+				Usage:       "Commit the writes of the statements run by --analyze",
+			},
+			&cli.StringFlag{
+				Name:        "explain-format",
+				Destination: &args.explainFormat,
+				Usage:       "Format of the --explain and --analyze plan (text, json), --analyze prints a tree without it",
+			},
+			&cli.IntFlag{
+				Name:        "repeat",
+				Destination: &args.repeat,
+				Usage:       "Run the statements N times and print latency statistics instead of the results",
+			},
+			&cli.IntFlag{
+				Name:        "warmup",
+				Destination: &args.warmup,
+				Usage:       "Runs before --repeat that are not measured",
+			},
 			&cli.StringFlag{
-				Name:        "url",
-				Destination: &args.url,
-				Usage:       "Connection string, e.g. postgres://<user>:<pw>@<host>:<port>/<db>",
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Destination: &outArgs.output,
+				Usage:       "Write the result to a file, s3://, gs:// or az:// object instead of stdout, compressed if it ends in .gz",
+			},
+			&cli.BoolFlag{
+				Name:        "append",
+				Destination: &outArgs.append,
+				Usage:       "Append to the --output file instead of replacing it",
+			},
+			&cli.BoolFlag{
+				Name:        "no-pager",
+				Destination: &outArgs.noPager,
+				Usage:       "Never pipe output through $PAGER",
 			},
 			&cli.StringFlag{
-				Name:        "host",
-				Destination: &args.host,
-				Usage:       "Host addres",
+				Name:        "format",
+				Destination: &outArgs.format,
+				Usage:       "Output format (table, json, ndjson, csv, tsv, yaml, xml, markdown, html, org, asciidoc, latex, xlsx, arrow, avro, sql, template), defaults to the --output file extension or table",
 			},
 			&cli.StringFlag{
-				Name:        "port",
-				Aliases:     []string{"p"},
-				Destination: &args.port,
-				Usage:       "Port",
+				Name:        "columns",
+				Destination: &outArgs.columns,
+				Usage:       "Comma separated list of columns to output, in the given order",
 			},
 			&cli.StringFlag{
-				Name:        "user",
-				Aliases:     []string{"u"},
-				Destination: &args.user,
-				Usage:       "User name",
+				Name:        "null",
+				Destination: &outArgs.null,
+				Usage:       "String to print for NULL values (default: null in tables, empty in csv, \\N in tsv)",
+				Action: func(*cli.Context, string) error {
+					outArgs.nullSet = true
+					return nil
+				},
 			},
 			&cli.StringFlag{
-				Name:        "password",
-				Aliases:     []string{"pw"},
-				Destination: &args.password,
-				Usage:       "Password",
+				Name:        "style",
+				Value:       "light",
+				Destination: &outArgs.style,
+				Usage:       "Table style (light, rounded, bold, double, ascii)",
 			},
 			&cli.StringFlag{
-				Name:        "db",
-				Destination: &args.database,
-				Usage:       "Database name",
+				Name:        "color",
+				Value:       "auto",
+				Destination: &outArgs.colorMode,
+				Usage:       "Colorize table output (on, off, auto), auto honors NO_COLOR",
 			},
 			&cli.BoolFlag{
-				Name:        "no-tx",
-				Destination: &args.noTx,
-				Usage:       "Run without transaction",
+				Name:        "no-header",
+				Aliases:     []string{"t"},
+				Destination: &outArgs.noHeader,
+				Usage:       "Print rows only, without header and borders (table, csv and tsv output)",
 			},
-		},
+			&cli.IntFlag{
+				Name:        "max-col-width",
+				Destination: &outArgs.maxColWidth,
+				Usage:       "Limit table columns to N characters, longer values are truncated with an ellipsis",
+			},
+			&cli.BoolFlag{
+				Name:        "truncate",
+				Destination: &outArgs.truncate,
+				Usage:       "Truncate values longer than --max-col-width (default)",
+			},
+			&cli.BoolFlag{
+				Name:        "wrap",
+				Destination: &outArgs.wrap,
+				Usage:       "Wrap values longer than --max-col-width onto multiple lines instead of truncating",
+			},
+			&cli.StringFlag{
+				Name:        "bytea",
+				Value:       "hex",
+				Destination: &outArgs.bytea,
+				Usage:       "Encoding of bytea values (hex, base64, escape, omit)",
+			},
+			&cli.StringFlag{
+				Name:        "time-format",
+				Destination: &outArgs.timeFormat,
+				Usage:       "Format of date and timestamp values, a Go layout like 2006-01-02 or strftime like %d.%m.%Y",
+			},
+			&cli.StringFlag{
+				Name:        "numeric-format",
+				Value:       "exact",
+				Destination: &outArgs.numericFormat,
+				Usage:       "Format of numeric values, exact or a pattern like 0.00 or #,##0.00",
+			},
+			&cli.StringFlag{
+				Name:        "numeric-locale",
+				Destination: &outArgs.numericLocale,
+				Usage:       "Locale for the decimal and group separators of numeric values, e.g. de or de-CH",
+			},
+			&cli.StringFlag{
+				Name:        "array-format",
+				Value:       "pg",
+				Destination: &outArgs.arrayFormat,
+				Usage:       "Format of arrays in text output, pg prints {a,b,c} literals, json prints JSON arrays",
+			},
+			&cli.BoolFlag{
+				Name:        "pretty-json",
+				Destination: &outArgs.prettyJSON,
+				Usage:       "Indent and highlight json and jsonb values in table output",
+			},
+			&cli.BoolFlag{
+				Name:        "unaligned",
+				Aliases:     []string{"A"},
+				Destination: &outArgs.unaligned,
+				Usage:       "Unaligned table output like psql -A",
+			},
+			&cli.StringFlag{
+				Name:        "field-separator",
+				Aliases:     []string{"F"},
+				Value:       "|",
+				Destination: &outArgs.fieldSep,
+				Usage:       "Field separator for unaligned output",
+			},
+			&cli.BoolFlag{
+				Name:        "expanded",
+				Aliases:     []string{"x"},
+				Destination: &outArgs.expanded,
+				Usage:       "Print each row as a vertical block of column and value in table output",
+			},
+			&cli.StringFlag{
+				Name:        "delimiter",
+				Destination: &outArgs.delimiter,
+				Usage:       "Field delimiter for csv and tsv output, e.g. ';', '|' or '\\t'",
+			},
+			&cli.StringFlag{
+				Name:        "target-table",
+				Destination: &outArgs.targetTable,
+				Usage:       "Table name used in sql output, inferred from the query if omitted",
+			},
+			&cli.StringFlag{
+				Name:        "template",
+				Destination: &outArgs.template,
+				Usage:       "Go text/template executed per row for template output, e.g. '{{.id}}\\t{{.email}}\\n'",
+			},
+			&cli.StringFlag{
+				Name:        "template-file",
+				Destination: &outArgs.templateFile,
+				Usage:       "Read the output template from a file",
+			},
+			&cli.BoolFlag{
+				Name:        "template-aggregate",
+				Destination: &outArgs.templateAggregate,
+				Usage:       "Execute the template once with .Columns and .Rows instead of once per row",
+			},
+			&cli.BoolFlag{
+				Name:        "html-css",
+				Destination: &outArgs.htmlCSS,
+				Usage:       "Include a <style> block with inline CSS in html output",
+			},
+			&cli.StringFlag{
+				Name:        "csv-quote",
+				Value:       "minimal",
+				Destination: &outArgs.csvQuote,
+				Usage:       "CSV quoting: minimal quotes fields only when needed, all quotes every field, none never quotes, needs --null and fails on values that need quotes",
+			},
+		),
 		Action: func(cCtx *cli.Context) error {
-			err := execCommand(cCtx.Context, args, cCtx.Args().Get(0))
-			return err
+			if err := applyProfile(cCtx, args.profile); err != nil {
+				return err
+			}
+			if err := loadEnvFile(&args); err != nil {
+				return err
+			}
+			inArgs.params = params.Value()
+			inArgs.variables = variables.Value()
+			inArgs.templateContext = templateContext.Value()
+			if args.wait {
+				if err := waitReady(cCtx.Context, &args); err != nil {
+					return err
+				}
+				if len(inArgs.sources) == 0 && cCtx.Args().Get(0) == "" && isTerminal(os.Stdin) {
+					return nil
+				}
+			}
+			if len(inArgs.sources) == 0 && cCtx.Args().Get(0) == "" && isTerminal(os.Stdin) {
+				return runREPL(cCtx.Context, args, outArgs, inArgs)
+			}
+			scripts, err := readSQL(inArgs, cCtx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			statements, err := scriptStatements(scripts, inArgs)
+			if err != nil {
+				return err
+			}
+			return execCommand(cCtx.Context, args, outArgs, statements)
+		},
+		Commands: []*cli.Command{
+			genCommand(&args),
+			authCommand(),
+			pingCommand(&args),
+			daemonCommand(&args),
+			importCommand(&args),
+			exportSQLiteCommand(&args),
 		},
 	}
+	// A broken config file only costs the aliases, the other commands
+	// don't need it.
+	aliases, err := aliasCommands(app, &args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring aliases: %v\n", err)
+	}
+	app.Commands = append(app.Commands, aliases...)
 
 	if err := app.Run(os.Args); err != nil {
+		if isTimeout(err) {
+			log.Println(err)
+			os.Exit(timeoutExitCode)
+		}
 		log.Fatal(err)
 	}
 }
 
+// connFlags returns the connection flags shared by the root command and
+// all subcommands.
+func connFlags(args *connArgs) []cli.Flag {
+	return []cli.Flag{
+		&cli.GenericFlag{
+			Name:  "url",
+			Value: &urlFlag{args: args},
+			Usage: "Connection string, e.g. postgres://<user>:<pw>@<host>:<port>/<db>, repeat to spread the statements across several servers",
+		},
+		&cli.StringFlag{
+			Name:        "profile",
+			Destination: &args.profile,
+			Usage:       "Take the flags of a profile in ~/.config/pgexec/config.toml or $PGEXEC_CONFIG",
+		},
+		&cli.StringFlag{
+			Name:        "daemon",
+			Destination: &args.daemon,
+			Usage:       "Run through the connections of pgexec daemon on this socket, defaults to $PGEXEC_DAEMON",
+		},
+		&cli.StringFlag{
+			Name:        "env-file",
+			Destination: &args.envFile,
+			Usage:       "Load DATABASE_URL and PG* variables from this file, defaults to .env if it exists",
+		},
+		&cli.StringFlag{
+			Name:        "service",
+			Destination: &args.service,
+			Usage:       "Connection service from ~/.pg_service.conf, $PGSERVICEFILE or the system pg_service.conf",
+		},
+		&cli.StringFlag{
+			Name:        "host",
+			Destination: &args.host,
+			Usage:       "Host address, or a comma separated list tried in order, defaults to $PGHOST",
+		},
+		&cli.StringFlag{
+			Name:        "target-session-attrs",
+			Destination: &args.targetSessionAttrs,
+			Usage:       "Session required from one of several hosts: any, read-write, read-only, primary, standby or prefer-standby",
+		},
+		&cli.StringFlag{
+			Name:        "socket",
+			Destination: &args.socket,
+			Usage:       "Unix domain socket directory or file, e.g. /var/run/postgresql",
+		},
+		&cli.StringFlag{
+			Name:        "port",
+			Aliases:     []string{"p"},
+			Destination: &args.port,
+			Usage:       "Port",
+		},
+		&cli.StringFlag{
+			Name:        "user",
+			Aliases:     []string{"u"},
+			Destination: &args.user,
+			Usage:       "User name",
+		},
+		&cli.StringFlag{
+			Name:        "password",
+			Aliases:     []string{"pw"},
+			Destination: &args.password,
+			Usage:       "Password",
+		},
+		&cli.BoolFlag{
+			Name:        "prompt-password",
+			Aliases:     []string{"W"},
+			Destination: &args.promptPassword,
+			Usage:       "Prompt for the password on the terminal",
+		},
+		&cli.StringFlag{
+			Name:        "password-command",
+			Destination: &args.passwordCommand,
+			Usage:       "Shell command printing the password, run for each new connection",
+		},
+		&cli.StringFlag{
+			Name:        "db",
+			Destination: &args.database,
+			Usage:       "Database name",
+		},
+		&cli.StringFlag{
+			Name:        "ssh",
+			Destination: &args.ssh,
+			Usage:       "Connect through an SSH tunnel to a jump host, user@host[:port]",
+		},
+		&cli.StringFlag{
+			Name:        "ssh-key",
+			Destination: &args.sshKey,
+			Usage:       "Private key for --ssh, ssh-agent and ~/.ssh/id_* are used by default",
+		},
+		&cli.BoolFlag{
+			Name:        "aws-iam-auth",
+			Destination: &args.awsIAMAuth,
+			Usage:       "Authenticate with an RDS IAM token generated from the AWS credentials",
+		},
+		&cli.StringFlag{
+			Name:        "aws-region",
+			Destination: &args.awsRegion,
+			Usage:       "AWS region for --aws-iam-auth, defaults to the AWS config or the RDS host name",
+		},
+		&cli.BoolFlag{
+			Name:        "azure-ad-auth",
+			Destination: &args.azureADAuth,
+			Usage:       "Authenticate with an Entra ID access token from the default Azure credential chain",
+		},
+		&cli.StringFlag{
+			Name:        "cloudsql-instance",
+			Destination: &args.cloudSQLInstance,
+			Usage:       "Connect to the Cloud SQL instance `project:region:instance` through the Cloud SQL connector",
+		},
+		&cli.BoolFlag{
+			Name:        "cloudsql-private-ip",
+			Destination: &args.cloudSQLPrivateIP,
+			Usage:       "Connect to the private IP of the --cloudsql-instance",
+		},
+		&cli.StringFlag{
+			Name:        "sslmode",
+			Destination: &args.sslMode,
+			Usage:       "TLS mode: disable, allow, prefer, require, verify-ca or verify-full",
+		},
+		&cli.BoolFlag{
+			Name:        "tls-skip-verify",
+			Destination: &args.tlsSkipVerify,
+			Usage:       "Encrypt the connection without verifying the server certificate, INSECURE",
+		},
+		&cli.StringFlag{
+			Name:        "channel-binding",
+			Destination: &args.channelBinding,
+			Usage:       "SCRAM channel binding: disable or prefer, require is not supported",
+		},
+		&cli.StringFlag{
+			Name:        "sslrootcert",
+			Destination: &args.sslRootCert,
+			Usage:       "CA certificate file used to verify the server",
+		},
+		&cli.StringFlag{
+			Name:        "sslcert",
+			Destination: &args.sslCert,
+			Usage:       "Client certificate file",
+		},
+		&cli.StringFlag{
+			Name:        "sslkey",
+			Destination: &args.sslKey,
+			Usage:       "Private key file of the client certificate",
+		},
+		&cli.StringFlag{
+			Name:        "sslpassword",
+			Destination: &args.sslPassword,
+			Usage:       "Passphrase of an encrypted --sslkey, prompted for if omitted",
+		},
+		&cli.StringFlag{
+			Name:        "application-name",
+			Destination: &args.applicationName,
+			Usage:       "application_name of the session, defaults to $PGAPPNAME or pgexec",
+		},
+		&cli.StringFlag{
+			Name:        "search-path",
+			Destination: &args.searchPath,
+			Usage:       "search_path of the session, e.g. app,public",
+		},
+		&cli.StringSliceFlag{
+			Name:        "set-guc",
+			Destination: &args.settings,
+			Usage:       "Set a setting name=value on the session, e.g. work_mem=256MB, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:        "role",
+			Destination: &args.role,
+			Usage:       "SET ROLE to this role after connecting",
+		},
+		&cli.DurationFlag{
+			Name:        "connect-timeout",
+			Destination: &args.connectTimeout,
+			Usage:       "Give up connecting after this long, defaults to $PGCONNECT_TIMEOUT",
+		},
+		&cli.IntFlag{
+			Name:        "max-conns",
+			Destination: &args.maxConns,
+			Usage:       "Maximum number of connections opened to the server",
+		},
+		&cli.BoolFlag{
+			Name:        "single-connection",
+			Destination: &args.singleConnection,
+			Usage:       "Run everything on one session, so session state like SET and temp tables carries over",
+		},
+		&cli.StringFlag{
+			Name:        "require-version",
+			Destination: &args.requireVersion,
+			Usage:       "Fail unless the server version satisfies a comparison like '>=14' or '>=14,<17'",
+		},
+		&cli.DurationFlag{
+			Name:        "keepalive",
+			Destination: &args.keepalive,
+			Usage:       "Interval of TCP keepalive probes, so dead connections are noticed, defaults to 5m",
+		},
+		&cli.DurationFlag{
+			Name:        "health-check-period",
+			Destination: &args.healthCheckPeriod,
+			Usage:       "Interval in which idle connections are checked, defaults to 1m",
+		},
+		&cli.DurationFlag{
+			Name:        "max-conn-idle-time",
+			Destination: &args.maxConnIdleTime,
+			Usage:       "Close connections that were idle this long, defaults to 30m",
+		},
+		&cli.BoolFlag{
+			Name:        "pooler-compat",
+			Destination: &args.poolerCompat,
+			Usage:       "Don't use prepared statements, for PgBouncer in transaction pooling mode",
+		},
+		&cli.IntFlag{
+			Name:        "connect-retries",
+			Destination: &args.connectRetries,
+			Usage:       "Retry connecting up to N times on transient failures like refused connections",
+		},
+		&cli.DurationFlag{
+			Name:        "connect-backoff",
+			Value:       time.Second,
+			Destination: &args.connectBackoff,
+			Usage:       "Wait before the first connection retry, doubled after every further attempt",
+		},
+		&cli.BoolFlag{
+			Name:        "wait",
+			Destination: &args.wait,
+			Usage:       "Wait until the database accepts connections, then run the SQL if any was given",
+		},
+		&cli.DurationFlag{
+			Name:        "wait-timeout",
+			Value:       time.Minute,
+			Destination: &args.waitTimeout,
+			Usage:       "Give up --wait after this long, 0 waits forever",
+		},
+		&cli.StringFlag{
+			Name:        "wait-query",
+			Destination: &args.waitQuery,
+			Usage:       "Readiness query for --wait, ready once it returns a row that isn't false",
+		},
+		&cli.StringFlag{
+			Name:        "krbsrvname",
+			Destination: &args.krbSrvName,
+			Usage:       "Kerberos service name of the server, defaults to postgres",
+		},
+		&cli.StringFlag{
+			Name:        "krbspn",
+			Destination: &args.krbSPN,
+			Usage:       "Kerberos service principal of the server, overrides --krbsrvname",
+		},
+		&cli.StringFlag{
+			Name:        "gssencmode",
+			Destination: &args.gssEncMode,
+			Usage:       "GSSAPI encryption mode: disable or prefer",
+		},
+	}
+}
+
+// stmtOutputArgs infers the sql output table from the statement unless it
+// was given.
+func stmtOutputArgs(outArgs outputArgs, stmt string) outputArgs {
+	if outArgs.targetTable == "" {
+		outArgs.targetTable = inferTable(stmt)
+	}
+	return outArgs
+}
+
 func trim(str string) string {
 	return strings.Trim(str, " \t\n\r")
 }
 
 func getConnPool(ctx context.Context, connArgs connArgs) (*pgxpool.Pool, error) {
-	if trim(connArgs.url) != "" {
-		return pgxpool.New(context.Background(), connArgs.url)
+	runtimeParams := map[string]string{}
+	for _, s := range connArgs.settings.Value() {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || trim(name) == "" {
+			return nil, fmt.Errorf("invalid --set-guc %q, expected name=value", s)
+		}
+		runtimeParams[trim(name)] = value
+	}
+	if connArgs.timeout > 0 {
+		runtimeParams["statement_timeout"] = strconv.FormatInt(connArgs.timeout.Milliseconds(), 10)
+	}
+	if connArgs.searchPath != "" {
+		runtimeParams["search_path"] = connArgs.searchPath
 	}
-	port, err := strconv.Atoi(trim(connArgs.port))
+	if connArgs.readOnly {
+		// Covers --no-transaction, where no transaction is started.
+		runtimeParams["default_transaction_read_only"] = "on"
+	}
+	config, err := poolConfig(ctx, connArgs)
 	if err != nil {
 		return nil, err
 	}
-	return pgxpool.NewWithConfig(ctx, &pgxpool.Config{
-		MaxConns: 10,
-		ConnConfig: &pgx.ConnConfig{
-			Config: pgconn.Config{
-				Host:     connArgs.host,
-				Port:     uint16(port),
-				Database: connArgs.database,
-				User:     connArgs.user,
-				Password: connArgs.password,
-			},
-		},
-	})
+	for k, v := range runtimeParams {
+		config.ConnConfig.RuntimeParams[k] = v
+	}
+	if connArgs.maxConns > 0 {
+		config.MaxConns = int32(connArgs.maxConns)
+	}
+	if connArgs.singleConnection {
+		// The session is never recycled, so its state carries over
+		// between statements.
+		config.MaxConns = 1
+		config.MaxConnLifetime = sessionLifetime
+		config.MaxConnIdleTime = sessionLifetime
+		// pgx closes the connection when a context is cancelled, which
+		// would end the session on Ctrl-C or --timeout. Ask the server to
+		// cancel the statement instead and only give up on the connection
+		// when it doesn't answer.
+		config.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+			return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadline}
+		}
+	}
+	if connArgs.healthCheckPeriod > 0 {
+		config.HealthCheckPeriod = connArgs.healthCheckPeriod
+	}
+	if connArgs.maxConnIdleTime > 0 {
+		config.MaxConnIdleTime = connArgs.maxConnIdleTime
+	}
+	if connArgs.poolerCompat {
+		// Prepared statements are tied to a server connection, which
+		// PgBouncer in transaction pooling mode may switch between
+		// messages.
+		config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	}
+	if connArgs.role != "" {
+		// Runs on every connection of the pool, before any transaction.
+		setRole := "SET ROLE " + pgx.Identifier{connArgs.role}.Sanitize()
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, setRole)
+			return err
+		}
+	}
+	if connArgs.requireVersion != "" {
+		constraints, err := parseVersionConstraints(connArgs.requireVersion)
+		if err != nil {
+			return nil, err
+		}
+		afterConnect := config.AfterConnect
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			version := conn.PgConn().ParameterStatus("server_version")
+			if err := checkServerVersion(constraints, version, connArgs.requireVersion); err != nil {
+				return err
+			}
+			if afterConnect != nil {
+				return afterConnect(ctx, conn)
+			}
+			return nil
+		}
+	}
+	if connArgs.singleConnection {
+		// A new connection would lose the session state without notice,
+		// so after the first one connecting again fails.
+		var connected atomic.Bool
+		afterConnect := config.AfterConnect
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if connected.Load() {
+				return errors.New("the connection of --single-connection was lost, and with it the session state")
+			}
+			if afterConnect != nil {
+				if err := afterConnect(ctx, conn); err != nil {
+					return err
+				}
+			}
+			connected.Store(true)
+			return nil
+		}
+	}
+	if connArgs.promptPassword {
+		return connectPrompting(ctx, config, connArgs)
+	}
+	if connArgs.connectRetries > 0 {
+		return connectRetrying(ctx, config, connArgs)
+	}
+	return pgxpool.NewWithConfig(ctx, config)
 }
 
 type Executor interface {
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 }
 
-func execCommand(ctx context.Context, connArgs connArgs, sql string) error {
-	pool, err := getConnPool(ctx, connArgs)
+func execCommand(ctx context.Context, connArgs connArgs, outArgs outputArgs, statements []sqlStatement) (err error) {
+	out, err := openOutput(outArgs)
 	if err != nil {
 		return err
 	}
-	defer pool.Close()
+	defer func() {
+		if closeErr := closeOutput(out, err); err == nil {
+			err = closeErr
+		}
+	}()
+	if len(statements) == 0 {
+		return errors.New("no query given")
+	}
+	if connArgs, outArgs, err = resolveArgs(connArgs, outArgs); err != nil {
+		return err
+	}
+	// Fail on invalid output flags before connecting, the sql output table
+	// is inferred from the first statement that names one.
+	check := outArgs
+	for _, stmt := range statements {
+		check = stmtOutputArgs(check, stmt.sql)
+	}
+	if _, err := newResultWriter(out, check); err != nil {
+		return err
+	}
+	if err := checkResultSets(connArgs, outArgs, statements); err != nil {
+		return err
+	}
 
-	var ex Executor
-	if connArgs.noTx {
-		ex = pool
-	} else {
-		tx, err := pool.Begin(ctx)
-		defer tx.Rollback(ctx)
+	if len(connArgs.urls) > 1 {
+		endpoints, err := openEndpoints(ctx, connArgs)
 		if err != nil {
 			return err
 		}
-		ex = tx
+		defer closeEndpoints(endpoints)
+		return execRoundRobin(ctx, endpoints, connArgs, out, outArgs, statements)
 	}
-	res, err := ex.Query(ctx, sql)
+	pool, err := getConnPool(ctx, connArgs)
 	if err != nil {
 		return err
 	}
-	vals := scanRowsToMaps(res)
+	defer pool.Close()
+	return runStatements(ctx, pool, connArgs, out, outArgs, statements)
+}
 
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.SetStyle(table.StyleLight)
-	t.Style().Format.Header = text.FormatDefault
+// singleDocumentFormats write one document or file container per result
+// set, several of them in one output make it invalid.
+var singleDocumentFormats = map[string]bool{
+	"json": true, "yaml": true, "xml": true, "xlsx": true, "arrow": true, "avro": true,
+}
 
-	header := table.Row{}
-	for _, v := range res.FieldDescriptions() {
-		header = append(header, v.Name)
+// checkResultSets fails when more than one statement returns rows with a
+// format that can hold only one result set.
+func checkResultSets(connArgs connArgs, outArgs outputArgs, statements []sqlStatement) error {
+	format := outputFormat(outArgs)
+	if !singleDocumentFormats[format] || connArgs.dryRun || connArgs.repeat > 0 {
+		return nil
 	}
-	t.AppendHeader(header)
-	for _, val := range vals {
-		row := table.Row{}
-		for _, field := range res.FieldDescriptions() {
-			row = append(row, val[field.Name])
+	n := 0
+	for _, stmt := range statements {
+		if (connArgs.explain || connArgs.analyze) && explainable[firstKeyword(stmt.sql)] {
+			// The tree of --analyze is printed as text instead of a result set.
+			if !connArgs.analyze || connArgs.explainFormat != "" {
+				n++
+			}
+		} else if returnsRows(stmt.sql) {
+			n++
 		}
-		t.AppendRow(row)
 	}
-	t.Render()
+	if n > 1 {
+		return fmt.Errorf("%d statements return rows, but the %s output holds only one result set", n, format)
+	}
+	return nil
+}
 
-	if tx, ok := ex.(pgx.Tx); ok {
-		err = tx.Commit(ctx)
-		if err != nil {
-			return err
+// resolveArgs validates the execution flags and fills in the settings
+// derived from them.
+func resolveArgs(connArgs connArgs, outArgs outputArgs) (connArgs, outputArgs, error) {
+	switch connArgs.onError {
+	case "rollback", "stop", "continue":
+	default:
+		return connArgs, outArgs, fmt.Errorf("unknown --on-error mode %q", connArgs.onError)
+	}
+	if connArgs.savepoints {
+		if connArgs.onError == "stop" {
+			return connArgs, outArgs, errors.New("--savepoints can't be combined with --on-error stop")
 		}
+		connArgs.onError = "continue"
 	}
+	if len(connArgs.urls) > 1 {
+		if err := checkEndpointArgs(connArgs); err != nil {
+			return connArgs, outArgs, err
+		}
+	}
+	if connArgs.singleConnection && connArgs.parallel > 1 {
+		return connArgs, outArgs, errors.New("--parallel can't be combined with --single-connection")
+	}
+	if connArgs.analyzeCommit && !connArgs.analyze {
+		return connArgs, outArgs, errors.New("--analyze-commit needs --analyze")
+	}
+	if connArgs.analyze && connArgs.noTx && !connArgs.analyzeCommit {
+		return connArgs, outArgs, errors.New("--analyze can't roll back with --no-transaction, pass --analyze-commit to keep the writes")
+	}
+	if connArgs.singleConnection && connArgs.maxConns > 0 {
+		return connArgs, outArgs, errors.New("--max-conns can't be combined with --single-connection, which uses one connection")
+	}
+	if connArgs.singleConnection && connArgs.maxConnIdleTime > 0 {
+		return connArgs, outArgs, errors.New("--max-conn-idle-time can't be combined with --single-connection, the session is never recycled")
+	}
+	if connArgs.singleConnection && connArgs.healthCheckPeriod > 0 {
+		return connArgs, outArgs, errors.New("--health-check-period can't be combined with --single-connection, the session is never recycled")
+	}
+	var err error
+	if connArgs.txOptions, err = transactionOptions(connArgs); err != nil {
+		return connArgs, outArgs, err
+	}
+	outArgs.color, err = colorEnabled(outArgs)
+	connArgs.echoColor = outArgs.color && isTerminal(os.Stderr)
+	return connArgs, outArgs, err
+}
 
+// runStatements executes the statements on the pool in the mode selected
+// by the flags.
+func runStatements(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, out io.Writer, outArgs outputArgs, statements []sqlStatement) error {
+	if connArgs.explain || connArgs.analyze {
+		var err error
+		if statements, err = explainStatements(statements, connArgs.explainFormat, connArgs.analyze); err != nil {
+			return err
+		}
+	}
+	if connArgs.dryRun {
+		return dryRun(ctx, pool, statements)
+	}
+	if connArgs.repeat > 0 {
+		return benchmark(ctx, pool, connArgs, out, statements)
+	}
+	if connArgs.parallel > 1 {
+		return execParallel(ctx, pool, connArgs, out, outArgs, statements)
+	}
+	verbose := len(statements) > 1
+	for _, batch := range transactionBatches(statements, connArgs.txPerFile) {
+		if err := execRetrying(ctx, pool, connArgs, out, os.Stderr, outArgs, batch, verbose); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func scanRowsToMaps(rows pgx.Rows) []map[string]interface{} {
-	var rowMaps []map[string]interface{}
-	fields := rows.FieldDescriptions()
-
-	for rows.Next() {
-		scans := make([]interface{}, len(fields))
-		row := make(map[string]interface{})
-
-		for i := range scans {
-			scans[i] = &scans[i]
-		}
-		rows.Scan(scans...)
-		for i, v := range scans {
-			var value = ""
-			if v != nil {
-				switch fields[i].DataTypeOID {
-				case pgtype.UUIDOID:
-					arr := v.([16]uint8)
-					uuidVal, err := uuid.FromBytes(arr[:])
-					if err != nil {
-						value = fmt.Sprintf("%x", v)
-					} else {
-						value = uuidVal.String()
+// transactionBatches groups the statements that run in one transaction,
+// all of them or those of each file with --tx-per-file.
+func transactionBatches(statements []sqlStatement, perFile bool) [][]sqlStatement {
+	if !perFile {
+		return [][]sqlStatement{statements}
+	}
+	var batches [][]sqlStatement
+	for i, stmt := range statements {
+		if i == 0 || stmt.file != statements[i-1].file {
+			batches = append(batches, nil)
+		}
+		batches[len(batches)-1] = append(batches[len(batches)-1], stmt)
+	}
+	return batches
+}
+
+// execBatch runs statements in a transaction unless --no-transaction is
+// given. The status of every statement, and of every file once it is done,
+// is printed to status when verbose.
+func execBatch(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, out, status io.Writer, outArgs outputArgs, statements []sqlStatement, verbose bool) error {
+	var ex Executor
+	if connArgs.noTx {
+		// Without a transaction every statement commits on its own, but they
+		// still share one session so SET and temp tables carry over.
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Release()
+		ex = conn
+	} else {
+		tx, err := pool.BeginTx(ctx, connArgs.txOptions)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		ex = tx
+	}
+	// EXPLAIN ANALYZE executes the statements, their writes are only kept
+	// with --analyze-commit. --explain never writes anything.
+	commit := func(tx pgx.Tx) error {
+		if connArgs.explain && !connArgs.analyze || connArgs.analyze && !connArgs.analyzeCommit {
+			return tx.Rollback(ctx)
+		}
+		return tx.Commit(ctx)
+	}
+	var failures []string
+	for i, stmt := range statements {
+		if connArgs.echo {
+			echo := stmt.sql + ";"
+			if connArgs.echoColor {
+				echo = highlightSQL(echo, 0)
+			}
+			fmt.Fprintln(status, echo)
+		}
+		stmtCtx, cancel := statementContext(ctx, connArgs.timeout)
+		tag, err := execGuarded(stmtCtx, ex, out, stmtOutputArgs(outArgs, stmt.sql), stmt, connArgs.onError != "rollback")
+		err = statementError(stmtCtx, connArgs.timeout, err)
+		cancel()
+		if err != nil {
+			if stmt.file != "" {
+				err = fmt.Errorf("%s: %w", stmt.file, err)
+			}
+			switch connArgs.onError {
+			case "continue":
+				fmt.Fprintf(status, "warning: skipped failing statement: %v\n", err)
+				failures = append(failures, fmt.Sprintf("%s: %v", statementSummary(stmt.sql), err))
+				continue
+			case "stop":
+				if tx, ok := ex.(pgx.Tx); ok {
+					if commitErr := commit(tx); commitErr != nil {
+						return errors.Join(err, commitErr)
 					}
-				case pgtype.BoolOID:
-					value = fmt.Sprintf("%t", v)
-				default:
-					value = fmt.Sprintf("%s", v)
 				}
-			} else {
-				value = "null"
 			}
-			row[fields[i].Name] = value
+			return err
+		}
+		if verbose || !tag.Select() {
+			fmt.Fprintln(status, tag)
+		}
+		if verbose && stmt.file != "" && (i == len(statements)-1 || statements[i+1].file != stmt.file) {
+			fmt.Fprintf(status, "%s: done\n", stmt.file)
+		}
+	}
+	if tx, ok := ex.(pgx.Tx); ok {
+		if err := commit(tx); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(status, "%d of %d statements failed:\n", len(failures), len(statements))
+		for _, f := range failures {
+			fmt.Fprintf(status, "  %s\n", f)
+		}
+		return fmt.Errorf("%d of %d statements failed", len(failures), len(statements))
+	}
+	return nil
+}
+
+// transactionOptions returns the options for the transactions pgexec
+// starts, from --isolation, --deferrable and --read-only.
+func transactionOptions(connArgs connArgs) (pgx.TxOptions, error) {
+	var opts pgx.TxOptions
+	switch connArgs.isolation {
+	case "":
+	case "read-committed":
+		opts.IsoLevel = pgx.ReadCommitted
+	case "repeatable-read":
+		opts.IsoLevel = pgx.RepeatableRead
+	case "serializable":
+		opts.IsoLevel = pgx.Serializable
+	default:
+		return opts, fmt.Errorf("unknown isolation level %q", connArgs.isolation)
+	}
+	if connArgs.deferrable {
+		opts.DeferrableMode = pgx.Deferrable
+	}
+	if connArgs.readOnly {
+		opts.AccessMode = pgx.ReadOnly
+	}
+	return opts, nil
+}
+
+// execGuarded runs a statement in a savepoint when guard is set, so a
+// failure in a transaction only rolls back the statement itself.
+func execGuarded(ctx context.Context, ex Executor, out io.Writer, outArgs outputArgs, stmt sqlStatement, guard bool) (pgconn.CommandTag, error) {
+	tx, ok := ex.(pgx.Tx)
+	if !guard || !ok {
+		return execStatement(ctx, ex, out, outArgs, stmt)
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	tag, err := execStatement(ctx, sp, out, outArgs, stmt)
+	if err != nil {
+		if rbErr := sp.Rollback(ctx); rbErr != nil {
+			return tag, errors.Join(err, rbErr)
+		}
+		return tag, err
+	}
+	return tag, sp.Commit(ctx)
+}
+
+// execStatement runs a single statement and writes its result set, if it
+// returns one, with a new writer.
+func execStatement(ctx context.Context, ex Executor, out io.Writer, outArgs outputArgs, stmt sqlStatement) (pgconn.CommandTag, error) {
+	if stmt.analyze {
+		return execAnalyze(ctx, ex, out, outArgs.color, stmt)
+	}
+	switch copyDirection(stmt.sql) {
+	case "to":
+		return execCopyOut(ctx, ex, out, stmt)
+	case "from":
+		return execCopyIn(ctx, ex, stmt)
+	}
+	res, err := ex.Query(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer res.Close()
+	if len(res.FieldDescriptions()) > 0 {
+		w, err := newResultWriter(out, outArgs)
+		if err != nil {
+			return pgconn.CommandTag{}, err
+		}
+		if err := writeRows(w, res); err != nil {
+			return pgconn.CommandTag{}, err
 		}
-		rowMaps = append(rowMaps, row)
 	}
-	return rowMaps
+	res.Close()
+	return res.CommandTag(), res.Err()
 }
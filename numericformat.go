@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// numericFormat renders numeric values from their exact decimal digits, so
+// formatting never goes through a float.
+type numericFormat struct {
+	// decimals is the number of fraction digits to round to, or -1 to keep
+	// the scale of the value.
+	decimals   int
+	grouping   bool
+	groupSep   string
+	decimalSep string
+}
+
+var exactNumeric = numericFormat{decimals: -1, decimalSep: "."}
+
+var numericPattern = regexp.MustCompile(`^(#,##)?0(\.(0+))?$`)
+
+// numericSeparators holds the group and decimal separators per language,
+// more specific locales are looked up before their language.
+var numericSeparators = map[string][2]string{
+	"en":    {",", "."},
+	"de":    {".", ","},
+	"de-ch": {"'", "."},
+	"de-at": {" ", ","},
+	"fr":    {" ", ","},
+	"fr-ch": {" ", "."},
+	"it":    {".", ","},
+	"it-ch": {"'", "."},
+	"es":    {".", ","},
+	"pt":    {".", ","},
+	"nl":    {".", ","},
+	"da":    {".", ","},
+	"sv":    {" ", ","},
+	"nb":    {" ", ","},
+	"fi":    {" ", ","},
+	"pl":    {" ", ","},
+	"cs":    {" ", ","},
+	"ru":    {" ", ","},
+	"ja":    {",", "."},
+	"zh":    {",", "."},
+}
+
+// parseNumericFormat parses --numeric-format and --numeric-locale. The
+// format is either exact or a pattern like 0.00 or #,##0.00, where the
+// zeros after the point give the number of decimals and #,## enables digit
+// grouping.
+func parseNumericFormat(format, locale string) (numericFormat, error) {
+	nf := exactNumeric
+	nf.groupSep = ","
+	if format != "" && format != "exact" {
+		m := numericPattern.FindStringSubmatch(format)
+		if m == nil {
+			return nf, fmt.Errorf("invalid numeric format %q, expected exact or a pattern like #,##0.00", format)
+		}
+		nf.grouping = m[1] != ""
+		nf.decimals = len(m[3])
+	}
+	if locale != "" {
+		tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+		tag, _, _ = strings.Cut(tag, ".")
+		seps, ok := numericSeparators[tag]
+		if !ok {
+			lang, _, _ := strings.Cut(tag, "-")
+			seps, ok = numericSeparators[lang]
+		}
+		if !ok {
+			return nf, fmt.Errorf("unsupported numeric locale %q", locale)
+		}
+		nf.groupSep, nf.decimalSep = seps[0], seps[1]
+	}
+	return nf, nil
+}
+
+func (nf numericFormat) format(n pgtype.Numeric) string {
+	switch {
+	case n.NaN:
+		return "NaN"
+	case n.InfinityModifier == pgtype.Infinity:
+		return "Infinity"
+	case n.InfinityModifier == pgtype.NegativeInfinity:
+		return "-Infinity"
+	}
+
+	digits := new(big.Int).Set(n.Int)
+	exp := int(n.Exp)
+	if nf.decimals >= 0 && -exp > nf.decimals {
+		// Round half away from zero like round() in Postgres.
+		div := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp-nf.decimals)), nil)
+		rem := new(big.Int)
+		digits.QuoRem(digits, div, rem)
+		rem.Abs(rem).Lsh(rem, 1)
+		if rem.Cmp(div) >= 0 {
+			digits.Add(digits, big.NewInt(int64(n.Int.Sign())))
+		}
+		exp = -nf.decimals
+	}
+	scale := max(-exp, nf.decimals, 0)
+	digits.Mul(digits, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp+scale)), nil))
+
+	s := digits.String()
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= scale {
+		s = strings.Repeat("0", scale-len(s)+1) + s
+	}
+	intPart, frac := s[:len(s)-scale], s[len(s)-scale:]
+	if nf.grouping {
+		intPart = groupDigits(intPart, nf.groupSep)
+	}
+	if frac == "" {
+		return sign + intPart
+	}
+	return sign + intPart + nf.decimalSep + frac
+}
+
+// groupDigits inserts sep between every group of three digits.
+func groupDigits(s, sep string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
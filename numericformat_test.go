@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestNumericFormat(t *testing.T) {
+	tests := []struct {
+		format, locale string
+		n              pgtype.Numeric
+		want           string
+	}{
+		{"", "", pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}, "123.45"},
+		{"exact", "", pgtype.Numeric{Int: big.NewInt(5), Exp: -3, Valid: true}, "0.005"},
+		{"0.00", "", pgtype.Numeric{Int: big.NewInt(12345), Exp: -3, Valid: true}, "12.35"},
+		{"0.00", "", pgtype.Numeric{Int: big.NewInt(-12345), Exp: -3, Valid: true}, "-12.35"},
+		{"0.00", "", pgtype.Numeric{Int: big.NewInt(7), Exp: 0, Valid: true}, "7.00"},
+		{"0", "", pgtype.Numeric{Int: big.NewInt(25), Exp: -1, Valid: true}, "3"},
+		{"#,##0.00", "", pgtype.Numeric{Int: big.NewInt(123456789), Exp: -2, Valid: true}, "1,234,567.89"},
+		{"#,##0.00", "de_DE.UTF-8", pgtype.Numeric{Int: big.NewInt(123456789), Exp: -2, Valid: true}, "1.234.567,89"},
+		{"#,##0", "de-CH", pgtype.Numeric{Int: big.NewInt(1234567), Exp: 0, Valid: true}, "1'234'567"},
+		{"", "", pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Valid: true}, "12000"},
+		{"", "", pgtype.Numeric{NaN: true, Valid: true}, "NaN"},
+		{"0.00", "", pgtype.Numeric{InfinityModifier: pgtype.NegativeInfinity, Valid: true}, "-Infinity"},
+	}
+	for _, tt := range tests {
+		nf, err := parseNumericFormat(tt.format, tt.locale)
+		if err != nil {
+			t.Errorf("parseNumericFormat(%q, %q): %v", tt.format, tt.locale, err)
+			continue
+		}
+		if got := nf.format(tt.n); got != tt.want {
+			t.Errorf("format %q, locale %q of %v = %q, want %q", tt.format, tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumericFormatErrors(t *testing.T) {
+	for _, args := range [][2]string{{"0.##", ""}, {"#,##", ""}, {"", "xx-YY"}} {
+		if _, err := parseNumericFormat(args[0], args[1]); err == nil {
+			t.Errorf("parseNumericFormat(%q, %q) succeeded, want an error", args[0], args[1])
+		}
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	tests := []struct {
+		s, sep, want string
+	}{
+		{"1", ",", "1"},
+		{"123", ",", "123"},
+		{"1234", ",", "1,234"},
+		{"1234567", " ", "1 234 567"},
+		{"123456", "'", "123'456"},
+	}
+	for _, tt := range tests {
+		if got := groupDigits(tt.s, tt.sep); got != tt.want {
+			t.Errorf("groupDigits(%q, %q) = %q, want %q", tt.s, tt.sep, got, tt.want)
+		}
+	}
+}
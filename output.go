@@ -0,0 +1,673 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"golang.org/x/term"
+)
+
+type outputArgs struct {
+	output        string
+	append        bool
+	noPager       bool
+	format        string
+	csvQuote      string
+	delimiter     string
+	htmlCSS       bool
+	expanded      bool
+	columns       string
+	null          string
+	nullSet       bool
+	color         bool
+	colorMode     string
+	style         string
+	noHeader      bool
+	prettyJSON    bool
+	bytea         string
+	timeFormat    string
+	numericFormat string
+	numericLocale string
+	arrayFormat   string
+	unaligned     bool
+	fieldSep      string
+
+	maxColWidth int
+	wrap        bool
+	truncate    bool
+
+	targetTable string
+
+	template          string
+	templateFile      string
+	templateAggregate bool
+}
+
+// resultWriter receives a result set row by row and renders it in a
+// specific output format.
+type resultWriter interface {
+	WriteHeader(fields []pgconn.FieldDescription) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+// outputExtensions maps output file extensions to the format used when no
+// --format is given.
+var outputExtensions = map[string]string{
+	".json":     "json",
+	".ndjson":   "ndjson",
+	".jsonl":    "ndjson",
+	".csv":      "csv",
+	".tsv":      "tsv",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".xml":      "xml",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".html":     "html",
+	".htm":      "html",
+	".org":      "org",
+	".adoc":     "asciidoc",
+	".asciidoc": "asciidoc",
+	".tex":      "latex",
+	".xlsx":     "xlsx",
+	".arrows":   "arrow",
+	".avro":     "avro",
+	".sql":      "sql",
+}
+
+func outputFormat(outArgs outputArgs) string {
+	if outArgs.format != "" {
+		return outArgs.format
+	}
+	output := strings.TrimSuffix(strings.ToLower(outArgs.output), ".gz")
+	if format, ok := outputExtensions[filepath.Ext(output)]; ok {
+		return format
+	}
+	return "table"
+}
+
+// openOutput returns the destination for the result set, which is either
+// stdout, the file given with --output or an upload to object storage.
+// Outputs ending in .gz are compressed.
+func openOutput(outArgs outputArgs) (io.WriteCloser, error) {
+	if outArgs.output == "" || outArgs.output == "-" {
+		if !outArgs.noPager {
+			if pager := newPagerWriter(os.Stdout); pager != nil {
+				return pager, nil
+			}
+		}
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	out, err := remoteOutput(outArgs.output)
+	if err != nil {
+		return nil, err
+	}
+	if out != nil && outArgs.append {
+		return nil, errors.New("--append is not supported for uploads")
+	}
+	if out == nil {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if outArgs.append {
+			switch format := outputFormat(outArgs); format {
+			case "xlsx", "arrow", "avro":
+				return nil, fmt.Errorf("--append is not supported for %s output", format)
+			}
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		out = &outputFile{path: outArgs.output, flag: flag}
+	}
+	if strings.HasSuffix(strings.ToLower(outArgs.output), ".gz") {
+		out = &gzipOutput{next: out}
+	}
+	return out, nil
+}
+
+// outputFile opens the output file on the first write, so a query that
+// fails before producing any output leaves an existing file untouched.
+type outputFile struct {
+	path string
+	flag int
+	f    *os.File
+	w    *bufio.Writer
+}
+
+func (o *outputFile) Write(p []byte) (int, error) {
+	if o.f == nil {
+		f, err := os.OpenFile(o.path, o.flag, 0o644)
+		if err != nil {
+			return 0, err
+		}
+		o.f = f
+		o.w = bufio.NewWriter(f)
+	}
+	return o.w.Write(p)
+}
+
+func (o *outputFile) Close() error {
+	if o.f == nil {
+		return nil
+	}
+	if err := o.w.Flush(); err != nil {
+		o.f.Close()
+		return err
+	}
+	return o.f.Close()
+}
+
+var tableStyles = map[string]table.Style{
+	"":        table.StyleLight,
+	"light":   table.StyleLight,
+	"rounded": table.StyleRounded,
+	"bold":    table.StyleBold,
+	"double":  table.StyleDouble,
+	"ascii":   table.StyleDefault,
+}
+
+// colorEnabled resolves the --color mode. In auto mode colors are used when
+// writing to a terminal and NO_COLOR isn't set.
+func colorEnabled(outArgs outputArgs) (bool, error) {
+	switch outArgs.colorMode {
+	case "", "auto":
+		toStdout := outArgs.output == "" || outArgs.output == "-"
+		return toStdout && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout), nil
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown color mode %q", outArgs.colorMode)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func newResultWriter(out io.Writer, outArgs outputArgs) (resultWriter, error) {
+	w, err := newFormatWriter(out, outArgs)
+	if err != nil {
+		return nil, err
+	}
+	if trim(outArgs.columns) != "" {
+		w = newColumnWriter(w, outArgs.columns)
+	}
+	return w, nil
+}
+
+func newFormatWriter(out io.Writer, outArgs outputArgs) (resultWriter, error) {
+	values, err := newValueFormat(outArgs)
+	if err != nil {
+		return nil, err
+	}
+	switch outputFormat(outArgs) {
+	case "table":
+		values.color = outArgs.color
+		if outArgs.unaligned {
+			return &unalignedWriter{out: bufio.NewWriter(out), values: values, sep: outArgs.fieldSep, noHeader: outArgs.noHeader, expanded: outArgs.expanded}, nil
+		}
+		if outArgs.expanded {
+			return &expandedWriter{out: bufio.NewWriter(out), values: values, noHeader: outArgs.noHeader}, nil
+		}
+		style, ok := tableStyles[outArgs.style]
+		if !ok {
+			return nil, fmt.Errorf("unknown table style %q", outArgs.style)
+		}
+		if outArgs.wrap && outArgs.truncate {
+			return nil, errors.New("--wrap and --truncate are mutually exclusive")
+		}
+		if (outArgs.wrap || outArgs.truncate) && outArgs.maxColWidth <= 0 {
+			return nil, errors.New("--wrap and --truncate need --max-col-width")
+		}
+		return &tableWriter{out: out, values: values, style: style, noHeader: outArgs.noHeader, maxColWidth: outArgs.maxColWidth, wrap: outArgs.wrap}, nil
+	case "markdown":
+		return &tableWriter{out: out, values: values, format: "markdown", escape: markdownEscaper.Replace}, nil
+	case "html":
+		return &tableWriter{out: out, values: values, format: "html", htmlCSS: outArgs.htmlCSS}, nil
+	case "org", "asciidoc", "latex":
+		return &docWriter{out: bufio.NewWriter(out), values: values, format: outputFormat(outArgs)}, nil
+	case "json":
+		return &jsonWriter{out: bufio.NewWriter(out), values: values}, nil
+	case "ndjson":
+		return &jsonWriter{out: bufio.NewWriter(out), values: values, lines: true}, nil
+	case "yaml":
+		return &yamlWriter{out: bufio.NewWriter(out), values: values}, nil
+	case "xml":
+		return &xmlWriter{out: bufio.NewWriter(out), values: values}, nil
+	case "xlsx":
+		return &xlsxWriter{out: out, values: values}, nil
+	case "arrow":
+		return &arrowWriter{out: out, values: values}, nil
+	case "avro":
+		return &avroWriter{out: out, values: values}, nil
+	case "template":
+		return newTemplateWriter(bufio.NewWriter(out), values, outArgs)
+	case "sql":
+		return newSQLWriter(bufio.NewWriter(out), values, outArgs.targetTable)
+	case "csv":
+		switch outArgs.csvQuote {
+		case "", "minimal", "all", "none":
+		default:
+			return nil, fmt.Errorf("unknown csv quoting mode %q", outArgs.csvQuote)
+		}
+		if outArgs.csvQuote == "none" && values.nullString("") == "" {
+			// Without quotes an empty string can't be told apart from NULL.
+			return nil, errors.New("--csv-quote none needs a non-empty --null, NULL and the empty string would both be written as an empty field")
+		}
+		delim, err := parseDelimiter(outArgs.delimiter, ',')
+		if err != nil {
+			return nil, err
+		}
+		return &csvWriter{out: bufio.NewWriter(out), values: values, delim: delim, quote: outArgs.csvQuote, null: values.nullString(""), eol: "\r\n", noHeader: outArgs.noHeader}, nil
+	case "tsv":
+		delim, err := parseDelimiter(outArgs.delimiter, '\t')
+		if err != nil {
+			return nil, err
+		}
+		return &csvWriter{out: bufio.NewWriter(out), values: values, delim: delim, escaper: tsvEscaper(delim), null: values.nullString(`\N`), eol: "\n", noHeader: outArgs.noHeader}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", outputFormat(outArgs))
+	}
+}
+
+// parseDelimiter accepts a single character or one of the escapes \t and
+// \s as field separator, falling back to def when empty.
+func parseDelimiter(s string, def rune) (rune, error) {
+	switch s {
+	case "":
+		return def, nil
+	case `\t`:
+		return '\t', nil
+	case `\s`:
+		return ' ', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r[0], nil
+}
+
+// columnWriter projects and reorders the columns of a result set before
+// passing it on.
+type columnWriter struct {
+	next    resultWriter
+	columns []string
+	indexes []int
+}
+
+func newColumnWriter(next resultWriter, columns string) *columnWriter {
+	w := &columnWriter{next: next}
+	for _, c := range strings.Split(columns, ",") {
+		if c = trim(c); c != "" {
+			w.columns = append(w.columns, c)
+		}
+	}
+	return w
+}
+
+func (w *columnWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	projected := make([]pgconn.FieldDescription, len(w.columns))
+	w.indexes = make([]int, len(w.columns))
+	for i, name := range w.columns {
+		idx := slices.IndexFunc(fields, func(f pgconn.FieldDescription) bool { return f.Name == name })
+		if idx < 0 {
+			return fmt.Errorf("column %q is not part of the result", name)
+		}
+		w.indexes[i] = idx
+		projected[i] = fields[idx]
+	}
+	return w.next.WriteHeader(projected)
+}
+
+func (w *columnWriter) WriteRow(values []any) error {
+	projected := make([]any, len(w.indexes))
+	for i, idx := range w.indexes {
+		projected[i] = values[idx]
+	}
+	return w.next.WriteRow(projected)
+}
+
+func (w *columnWriter) Close() error {
+	return w.next.Close()
+}
+
+func writeRows(w resultWriter, rows pgx.Rows) error {
+	fields := rows.FieldDescriptions()
+	if err := w.WriteHeader(fields); err != nil {
+		return err
+	}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		rawJSONValues(fields, values, rows.RawValues())
+		if err := rawArrayValues(fields, values, rows.RawValues()); err != nil {
+			return err
+		}
+		if err := w.WriteRow(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// tableWriter buffers the result set in a go-pretty table and renders it
+// in one of the go-pretty output formats on Close.
+type tableWriter struct {
+	out      io.Writer
+	values   *valueFormat
+	style    table.Style
+	format   string
+	escape   func(string) string
+	htmlCSS  bool
+	noHeader bool
+	fields   []pgconn.FieldDescription
+	t        table.Writer
+
+	maxColWidth int
+	wrap        bool
+}
+
+func (w *tableWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	w.t = table.NewWriter()
+	w.t.SetStyle(w.style)
+	w.t.Style().Format.Header = text.FormatDefault
+	if w.values.color {
+		w.t.Style().Color.Header = text.Colors{text.Bold}
+		w.t.Style().Options.DoNotColorBordersAndSeparators = true
+	}
+	if w.maxColWidth > 0 {
+		enforcer := truncateEllipsis
+		if w.wrap {
+			enforcer = text.WrapSoft
+		}
+		configs := make([]table.ColumnConfig, len(fields))
+		for i := range fields {
+			configs[i] = table.ColumnConfig{Number: i + 1, WidthMax: w.maxColWidth, WidthMaxEnforcer: enforcer}
+		}
+		w.t.SetColumnConfigs(configs)
+	}
+	if w.noHeader {
+		w.t.Style().Options.DrawBorder = false
+		w.t.Style().Box.PaddingLeft = ""
+		w.t.Style().Box.PaddingRight = ""
+		w.t.Style().Box.MiddleVertical = " " + w.style.Box.MiddleVertical + " "
+		return nil
+	}
+
+	header := table.Row{}
+	for _, v := range fields {
+		header = append(header, w.cell(v.Name))
+	}
+	w.t.AppendHeader(header)
+	return nil
+}
+
+func (w *tableWriter) WriteRow(values []any) error {
+	row := table.Row{}
+	for i, v := range values {
+		row = append(row, w.cell(w.values.display(w.fields[i], v)))
+	}
+	w.t.AppendRow(row)
+	return nil
+}
+
+func (w *tableWriter) cell(s string) string {
+	if w.escape == nil {
+		return s
+	}
+	return w.escape(s)
+}
+
+func (w *tableWriter) Close() error {
+	var out string
+	switch w.format {
+	case "markdown":
+		out = w.t.RenderMarkdown()
+	case "html":
+		out = w.t.RenderHTML()
+		if w.htmlCSS {
+			out = htmlStyle + out
+		}
+	default:
+		out = w.t.Render()
+		if w.noHeader {
+			out = trimTrailingSpace(out)
+		}
+	}
+	if out == "" {
+		return nil
+	}
+	_, err := io.WriteString(w.out, out+"\n")
+	return err
+}
+
+// truncateEllipsis cuts every line of a cell to maxLen, marking truncated
+// lines with an ellipsis.
+func truncateEllipsis(s string, maxLen int) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if text.RuneWidthWithoutEscSequences(l) > maxLen {
+			lines[i] = text.Trim(l, maxLen-1) + "…"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimTrailingSpace drops the padding go-pretty adds after the last column,
+// so tuples-only output can be captured in shell variables as is.
+func trimTrailingSpace(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlStyle is written in front of html output with --html-css. It only
+// uses plain selectors so it survives most mail clients.
+const htmlStyle = `<style>
+table.go-pretty-table { border-collapse: collapse; font-family: sans-serif; font-size: 14px; }
+table.go-pretty-table th, table.go-pretty-table td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: left; vertical-align: top; }
+table.go-pretty-table th { background-color: #f6f8fa; font-weight: bold; }
+table.go-pretty-table tr:nth-child(even) td { background-color: #fafbfc; }
+</style>
+`
+
+// markdownEscaper escapes characters that would otherwise be interpreted as
+// inline markup. Pipes and newlines are handled by go-pretty itself.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "<", "&lt;", ">", "&gt;")
+
+// jsonWriter emits the result set as a JSON array of objects, keeping the
+// column order of the query. With lines set it writes one object per line
+// (NDJSON) instead, without any surrounding array.
+type jsonWriter struct {
+	out    *bufio.Writer
+	values *valueFormat
+	lines  bool
+	fields []pgconn.FieldDescription
+	keys   [][]byte
+	rows   int
+}
+
+func (w *jsonWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	w.keys = make([][]byte, len(fields))
+	for i, f := range fields {
+		key, err := json.Marshal(f.Name)
+		if err != nil {
+			return err
+		}
+		w.keys[i] = key
+	}
+	if w.lines {
+		return nil
+	}
+	_, err := w.out.WriteString("[")
+	return err
+}
+
+func (w *jsonWriter) WriteRow(values []any) error {
+	if w.lines {
+		if err := w.writeObject(values); err != nil {
+			return err
+		}
+		_, err := w.out.WriteString("\n")
+		return err
+	}
+	if w.rows > 0 {
+		w.out.WriteString(",")
+	}
+	w.out.WriteString("\n  ")
+	if err := w.writeObject(values); err != nil {
+		return err
+	}
+	w.rows++
+	return nil
+}
+
+func (w *jsonWriter) writeObject(values []any) error {
+	w.out.WriteString("{")
+	for i, v := range values {
+		if i > 0 {
+			w.out.WriteString(",")
+		}
+		w.out.Write(w.keys[i])
+		w.out.WriteString(":")
+		b, err := json.Marshal(w.values.json(w.fields[i], v))
+		if err != nil {
+			return err
+		}
+		w.out.Write(b)
+	}
+	_, err := w.out.WriteString("}")
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	if w.lines {
+		return w.out.Flush()
+	}
+	if w.rows > 0 {
+		w.out.WriteString("\n")
+	}
+	w.out.WriteString("]\n")
+	return w.out.Flush()
+}
+
+// csvWriter emits RFC 4180 CSV with a header row. NULL is written as an
+// empty unquoted field (or the --null string) while values that look the
+// same are always quoted, so the two stay distinguishable.
+//
+// With an escaper set it writes TSV instead: fields are never quoted and
+// delimiters, newlines and backslashes are backslash escaped like in COPY
+// text format, which also keeps the default NULL marker \N unambiguous.
+type csvWriter struct {
+	out     *bufio.Writer
+	values  *valueFormat
+	null    string
+	delim   rune
+	quote   string
+	escaper *strings.Replacer
+	eol     string
+	fields  []pgconn.FieldDescription
+
+	noHeader bool
+}
+
+func (w *csvWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	if w.noHeader {
+		return nil
+	}
+	for i, f := range fields {
+		if i > 0 {
+			w.out.WriteRune(w.delim)
+		}
+		if err := w.writeField(f.Name); err != nil {
+			return err
+		}
+	}
+	_, err := w.out.WriteString(w.eol)
+	return err
+}
+
+func (w *csvWriter) WriteRow(values []any) error {
+	for i, v := range values {
+		if i > 0 {
+			w.out.WriteRune(w.delim)
+		}
+		if v == nil {
+			w.out.WriteString(w.null)
+			continue
+		}
+		if err := w.writeField(w.values.text(w.fields[i], v)); err != nil {
+			return fmt.Errorf("column %s: %w", w.fields[i].Name, err)
+		}
+	}
+	_, err := w.out.WriteString(w.eol)
+	return err
+}
+
+// writeField writes a field, quoted if needed. With --csv-quote none a
+// field that needs quotes or equals --null is an error, as it would
+// corrupt the CSV.
+func (w *csvWriter) writeField(s string) error {
+	needsQuotes := strings.ContainsAny(s, string(w.delim)+"\"\r\n")
+	switch {
+	case w.escaper != nil:
+		w.out.WriteString(w.escaper.Replace(s))
+	case w.quote == "none":
+		if needsQuotes {
+			return errors.New("the value contains the delimiter, a quote or a line break, which --csv-quote none can't write")
+		}
+		if s == w.null {
+			return errors.New("the value equals the --null string, which --csv-quote none can't tell apart from NULL")
+		}
+		w.out.WriteString(s)
+	case w.quote == "all", s == "", s == w.null, needsQuotes:
+		w.out.WriteByte('"')
+		w.out.WriteString(strings.ReplaceAll(s, `"`, `""`))
+		w.out.WriteByte('"')
+	default:
+		w.out.WriteString(s)
+	}
+	return nil
+}
+
+func tsvEscaper(delim rune) *strings.Replacer {
+	pairs := []string{`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`}
+	if delim != '\t' {
+		pairs = append(pairs, string(delim), `\`+string(delim))
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+func (w *csvWriter) Close() error {
+	return w.out.Flush()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// testFields are an int8 and a text column.
+var testFields = []pgconn.FieldDescription{
+	{Name: "id", DataTypeOID: pgtype.Int8OID},
+	{Name: "name", DataTypeOID: pgtype.TextOID},
+}
+
+// render writes a result set in the format of outArgs.
+func render(t *testing.T, outArgs outputArgs, fields []pgconn.FieldDescription, rows ...[]any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newResultWriter(&buf, outArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestJSONWriter(t *testing.T) {
+	tests := []struct {
+		format string
+		rows   [][]any
+		want   string
+	}{
+		{"json", nil, "[]\n"},
+		{"json", [][]any{{int64(1), "a\"b"}, {int64(2), nil}}, "[\n  {\"id\":1,\"name\":\"a\\\"b\"},\n  {\"id\":2,\"name\":null}\n]\n"},
+		{"ndjson", nil, ""},
+		{"ndjson", [][]any{{int64(1), "x"}, {nil, "y"}}, "{\"id\":1,\"name\":\"x\"}\n{\"id\":null,\"name\":\"y\"}\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, outputArgs{format: tt.format}, testFields, tt.rows...); got != tt.want {
+			t.Errorf("%s of %v = %q, want %q", tt.format, tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestJSONWriterKeepsColumnOrder(t *testing.T) {
+	fields := []pgconn.FieldDescription{
+		{Name: "z", DataTypeOID: pgtype.TextOID},
+		{Name: "a", DataTypeOID: pgtype.TextOID},
+	}
+	want := "{\"z\":\"1\",\"a\":\"2\"}\n"
+	if got := render(t, outputArgs{format: "ndjson"}, fields, []any{"1", "2"}); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	tests := []struct {
+		outArgs outputArgs
+		rows    [][]any
+		want    string
+	}{
+		{outputArgs{format: "csv"}, [][]any{{int64(1), "plain"}}, "id,name\r\n1,plain\r\n"},
+		{outputArgs{format: "csv"}, [][]any{{int64(1), "a,b"}, {int64(2), "say \"hi\""}, {int64(3), "two\nlines"}, {int64(4), "cr\r"}}, "id,name\r\n1,\"a,b\"\r\n2,\"say \"\"hi\"\"\"\r\n3,\"two\nlines\"\r\n4,\"cr\r\"\r\n"},
+		// NULL is an empty field, the empty string is quoted.
+		{outputArgs{format: "csv"}, [][]any{{nil, ""}}, "id,name\r\n,\"\"\r\n"},
+		{outputArgs{format: "csv", null: "NULL", nullSet: true}, [][]any{{nil, "NULL"}}, "id,name\r\nNULL,\"NULL\"\r\n"},
+		{outputArgs{format: "csv", csvQuote: "all", noHeader: true}, [][]any{{int64(1), "x"}}, "\"1\",\"x\"\r\n"},
+		{outputArgs{format: "csv", csvQuote: "none", null: `\N`, nullSet: true}, [][]any{{int64(1), "x y"}, {nil, ""}}, "id,name\r\n1,x y\r\n\\N,\r\n"},
+		{outputArgs{format: "csv", delimiter: ";"}, [][]any{{int64(1), "a;b,c"}}, "id;name\r\n1;\"a;b,c\"\r\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.outArgs, testFields, tt.rows...); got != tt.want {
+			t.Errorf("csv of %v = %q, want %q", tt.rows, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriterQuoteNoneRejectsSpecialValues(t *testing.T) {
+	if _, err := newResultWriter(&bytes.Buffer{}, outputArgs{format: "csv", csvQuote: "none"}); err == nil {
+		t.Error("--csv-quote none without --null succeeded, want an error")
+	}
+	for _, s := range []string{"a,b", `a"b`, "a\nb", "a\rb", `\N`} {
+		w, err := newResultWriter(&bytes.Buffer{}, outputArgs{format: "csv", csvQuote: "none", null: `\N`, nullSet: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteHeader(testFields); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteRow([]any{int64(1), s}); err == nil {
+			t.Errorf("WriteRow(%q) with --csv-quote none succeeded, want an error", s)
+		}
+	}
+}
+
+func TestTSVWriter(t *testing.T) {
+	tests := []struct {
+		outArgs outputArgs
+		rows    [][]any
+		want    string
+	}{
+		{outputArgs{format: "tsv"}, [][]any{{int64(1), "a\tb\\c\nd"}, {nil, ""}}, "id\tname\n1\ta\\tb\\\\c\\nd\n\\N\t\n"},
+		{outputArgs{format: "tsv", delimiter: "|", null: "NULL", nullSet: true}, [][]any{{int64(1), "a|b"}, {nil, "x"}}, "id|name\n1|a\\|b\nNULL|x\n"},
+		{outputArgs{format: "tsv", noHeader: true}, [][]any{{int64(1), "x"}}, "1\tx\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, tt.outArgs, testFields, tt.rows...); got != tt.want {
+			t.Errorf("tsv of %v = %q, want %q", tt.rows, got, tt.want)
+		}
+	}
+}
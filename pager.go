@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"golang.org/x/term"
+)
+
+// defaultPager passes the colors of table output through, like psql and git.
+const defaultPager = "less -SR"
+
+// pagerWriter holds back output until it is known whether it fits on the
+// terminal. Once it gets taller or wider than the terminal the output is
+// piped through $PAGER instead of stdout.
+type pagerWriter struct {
+	out    *os.File
+	pager  string
+	width  int
+	height int
+
+	buf      bytes.Buffer
+	lines    int
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	quit     bool
+	overflow bool
+}
+
+// newPagerWriter returns a pagerWriter for out, or nil when paging is
+// disabled or out isn't a terminal.
+func newPagerWriter(out *os.File) *pagerWriter {
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		pager = defaultPager
+	}
+	if trim(pager) == "" || !isTerminal(out) {
+		return nil
+	}
+	width, height, err := term.GetSize(int(out.Fd()))
+	if err != nil {
+		return nil
+	}
+	return &pagerWriter{out: out, pager: pager, width: width, height: height}
+}
+
+func (p *pagerWriter) Write(b []byte) (int, error) {
+	if p.quit {
+		return len(b), nil
+	}
+	if p.stdin != nil {
+		return p.writePager(b)
+	}
+
+	p.buf.Write(b)
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if bytes.HasSuffix(line, []byte("\n")) {
+			p.lines++
+		}
+		if text.RuneWidthWithoutEscSequences(string(line)) > p.width {
+			p.overflow = true
+		}
+	}
+	if p.lines < p.height-1 && !p.overflow {
+		return len(b), nil
+	}
+
+	if err := p.startPager(); err != nil {
+		return 0, err
+	}
+	if _, err := p.writePager(p.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	p.buf.Reset()
+	return len(b), nil
+}
+
+func (p *pagerWriter) startPager() error {
+	p.cmd = exec.Command("sh", "-c", p.pager)
+	p.cmd.Stdout = p.out
+	p.cmd.Stderr = os.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := p.cmd.Start(); err != nil {
+		return err
+	}
+	p.stdin = stdin
+	return nil
+}
+
+// writePager forwards output to the pager. When the user quits the pager
+// early the remaining output is discarded.
+func (p *pagerWriter) writePager(b []byte) (int, error) {
+	n, err := p.stdin.Write(b)
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+		p.quit = true
+		return len(b), nil
+	}
+	return n, err
+}
+
+func (p *pagerWriter) Close() error {
+	if p.stdin == nil {
+		_, err := p.out.Write(p.buf.Bytes())
+		return err
+	}
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
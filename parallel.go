@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/errgroup"
+)
+
+// fileResult is the collected output of a file executed by execParallel.
+type fileResult struct {
+	out    bytes.Buffer
+	status bytes.Buffer
+	err    error
+	done   chan struct{}
+}
+
+// execParallel runs the files of a script concurrently on up to --parallel
+// connections of the pool, each in its own transaction. The output and
+// status of every file are collected and written in file order once it is
+// done, so the results of different files never interleave. After a file
+// fails no further files are started.
+func execParallel(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, out io.Writer, outArgs outputArgs, statements []sqlStatement) error {
+	batches := transactionBatches(statements, true)
+	results := make([]*fileResult, len(batches))
+	for i := range results {
+		results[i] = &fileResult{done: make(chan struct{})}
+	}
+
+	var failed atomic.Bool
+	var g errgroup.Group
+	g.SetLimit(connArgs.parallel)
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		for i, batch := range batches {
+			r, batch := results[i], batch
+			g.Go(func() error {
+				defer close(r.done)
+				if failed.Load() {
+					return nil
+				}
+				r.err = execRetrying(ctx, pool, connArgs, &r.out, &r.status, outArgs, batch, true)
+				if r.err != nil {
+					failed.Store(true)
+				}
+				return nil
+			})
+		}
+	}()
+
+	// Every file is waited for even after writing fails, so no worker
+	// still runs a statement once this returns.
+	var errs []error
+	var writeErr error
+	for _, r := range results {
+		<-r.done
+		if writeErr != nil {
+			continue
+		}
+		if _, writeErr = out.Write(r.out.Bytes()); writeErr != nil {
+			failed.Store(true)
+			continue
+		}
+		os.Stderr.Write(r.status.Bytes())
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	<-dispatched
+	g.Wait()
+	if writeErr != nil {
+		return writeErr
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// passwordCommand gets the password from the output of a shell command
+// for each new connection, so short-lived credentials from Vault or LDAP
+// helpers are fetched fresh and never written to disk.
+func passwordCommand(config *pgxpool.Config, connArgs connArgs) error {
+	switch {
+	case connArgs.password != "":
+		return errors.New("pass either --password or --password-command, not both")
+	case connArgs.promptPassword:
+		return errors.New("pass either --prompt-password or --password-command, not both")
+	case connArgs.awsIAMAuth:
+		return errors.New("--password-command can't be combined with IAM authentication")
+	case connArgs.azureADAuth:
+		return errors.New("--password-command can't be combined with --azure-ad-auth")
+	}
+	command := connArgs.passwordCommand
+	config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		password, err := runPasswordCommand(ctx, command)
+		if err != nil {
+			return err
+		}
+		cc.Password = password
+		return nil
+	}
+	return nil
+}
+
+// runPasswordCommand returns the first line the command writes to stdout.
+// Its stderr goes to the terminal, for helpers that ask for a login.
+func runPasswordCommand(ctx context.Context, command string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("password command: %w", err)
+	}
+	password, _, _ := strings.Cut(stdout.String(), "\n")
+	password = strings.TrimSuffix(password, "\r")
+	if password == "" {
+		return "", errors.New("password command printed no password")
+	}
+	return password, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func pingCommand(args *connArgs) *cli.Command {
+	return &cli.Command{
+		Name:      "ping",
+		Usage:     "Check the connection and print the server version, TLS, role and latency",
+		UsageText: "pgexec ping --url \"postgres://...\"",
+		Flags:     connFlags(args),
+		Action: func(cCtx *cli.Context) error {
+			if err := applyProfile(cCtx, args.profile); err != nil {
+				return err
+			}
+			if err := loadEnvFile(args); err != nil {
+				return err
+			}
+			return ping(cCtx.Context, *args, os.Stdout)
+		},
+	}
+}
+
+// ping connects and reports what the connection ended up with, so wrong
+// credentials, a missing TLS setup or a replica instead of the primary
+// show up before running anything.
+func ping(ctx context.Context, connArgs connArgs, out io.Writer) error {
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	start := time.Now()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	connectTime := time.Since(start)
+
+	start = time.Now()
+	if err := conn.Ping(ctx); err != nil {
+		return err
+	}
+	latency := time.Since(start)
+
+	var currentUser, sessionUser, database string
+	var inRecovery bool
+	err = conn.QueryRow(ctx, "SELECT current_user, session_user, current_database(), pg_is_in_recovery()").
+		Scan(&currentUser, &sessionUser, &database, &inRecovery)
+	if err != nil {
+		return err
+	}
+
+	pgConn := conn.Conn().PgConn()
+	role := currentUser
+	if currentUser != sessionUser {
+		role = fmt.Sprintf("%s (logged in as %s)", currentUser, sessionUser)
+	}
+	server := "PostgreSQL " + pgConn.ParameterStatus("server_version")
+	if inRecovery {
+		server += ", standby"
+	}
+	tlsInfo := "off"
+	if tlsConn, ok := pgConn.Conn().(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		tlsInfo = tls.VersionName(state.Version) + " " + tls.CipherSuiteName(state.CipherSuite)
+	}
+	// The address connected to, which may be any of several hosts.
+	fmt.Fprintf(out, "host      %s\n", pgConn.Conn().RemoteAddr())
+	fmt.Fprintf(out, "server    %s\n", server)
+	fmt.Fprintf(out, "database  %s\n", database)
+	fmt.Fprintf(out, "role      %s\n", role)
+	fmt.Fprintf(out, "tls       %s\n", tlsInfo)
+	fmt.Fprintf(out, "connect   %s\n", millis(connectTime))
+	fmt.Fprintf(out, "latency   %s\n", millis(latency))
+	return nil
+}
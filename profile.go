@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v2"
+)
+
+// pgexecConfig is the config file with the connection aliases and named
+// profiles. The keys of profiles are flag names:
+//
+//	[aliases]
+//	prod = "postgres://app@db.internal/shop"
+//
+//	[profiles.prod-replica]
+//	url = "postgres://reporting@replica.db.internal/shop"
+//	sslmode = "verify-full"
+//	role = "analytics_ro"
+//	format = "csv"
+type pgexecConfig struct {
+	Aliases  map[string]string         `toml:"aliases"`
+	Profiles map[string]map[string]any `toml:"profiles"`
+}
+
+// configFile returns the path of the config file,
+// ~/.config/pgexec/config.toml unless PGEXEC_CONFIG is set.
+func configFile() (string, error) {
+	if path := os.Getenv("PGEXEC_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "pgexec", "config.toml"), nil
+}
+
+// applyProfile sets the flags of the --profile that weren't given on the
+// command line, so they can still be overridden per invocation. Arrays set
+// repeatable flags once per element. Without a password the one stored
+// with auth login is used.
+func applyProfile(cCtx *cli.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, err := loadProfile(name)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(profile))
+	for key := range profile {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key == "profile" || cCtx.IsSet(key) {
+			continue
+		}
+		values, ok := profile[key].([]any)
+		if !ok {
+			values = []any{profile[key]}
+		}
+		for _, v := range values {
+			if err := cCtx.Set(key, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("profile %s: %s: %w", name, key, err)
+			}
+		}
+	}
+	if _, ok := profile["password"]; !ok && !cCtx.IsSet("password") {
+		if password, ok := keyringPassword(name); ok {
+			return cCtx.Set("password", password)
+		}
+	}
+	return nil
+}
+
+// loadProfile returns the flags of a profile in the config file.
+func loadProfile(name string) (map[string]any, error) {
+	path, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+	var config pgexecConfig
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return nil, err
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return profile, nil
+}
+
+// aliasCommands returns a command for every alias in the config file,
+// which runs the root command with the URL of the alias. Flags override
+// parts of the URL, e.g. pgexec prod --db tenant_42 "SELECT ...".
+func aliasCommands(app *cli.App, args *connArgs) ([]*cli.Command, error) {
+	path, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+	var config pgexecConfig
+	if _, err := toml.DecodeFile(path, &config); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(config.Aliases))
+	for name := range config.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var commands []*cli.Command
+	for _, name := range names {
+		if app.Command(name) != nil {
+			return nil, fmt.Errorf("alias %q in %s clashes with the %s command", name, path, name)
+		}
+		url := config.Aliases[name]
+		commands = append(commands, &cli.Command{
+			Name:                   name,
+			Usage:                  "Connect to the alias " + name,
+			Flags:                  app.Flags,
+			UseShortOptionHandling: true,
+			Before: func(*cli.Context) error {
+				if len(args.urls) == 0 {
+					args.url = url
+				}
+				return nil
+			},
+			Action: app.Action,
+		})
+	}
+	return commands, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/term"
+)
+
+// passwordAttempts is how often -W prompts before giving up.
+const passwordAttempts = 3
+
+// readSecret prompts for a password or passphrase on the terminal without
+// echoing it.
+func readSecret(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// connectPrompting prompts for the password and checks it right away by
+// connecting, so a mistyped password can be entered again. Each password
+// is tried with --connect-retries.
+func connectPrompting(ctx context.Context, config *pgxpool.Config, connArgs connArgs) (*pgxpool.Pool, error) {
+	for attempt := 1; ; attempt++ {
+		password, err := readSecret(fmt.Sprintf("Password for user %s: ", config.ConnConfig.User))
+		if err != nil {
+			return nil, err
+		}
+		config.ConnConfig.Password = password
+		pool, err := connectRetrying(ctx, config, connArgs)
+		if err == nil {
+			return pool, nil
+		}
+		if !isAuthFailure(err) || attempt == passwordAttempts {
+			return nil, err
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// isAuthFailure reports whether the server rejected the password.
+func isAuthFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "28P01" || pgErr.Code == "28000")
+}
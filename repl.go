@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// repl is an interactive session started when pgexec runs on a terminal
+// without any SQL to execute.
+type repl struct {
+	pool     *pgxpool.Pool
+	connArgs connArgs
+	outArgs  outputArgs
+	parser   *scriptParser
+	rl       *readline.Instance
+	complete *completer
+	// history is the history file, readline only keeps the entries in
+	// memory as it can't store multi-line inputs.
+	history string
+
+	// buf holds the lines of a statement that isn't terminated yet.
+	buf strings.Builder
+	// lastEntry is the last history entry, to skip repeated inputs.
+	lastEntry string
+	// lastInput is the last executed input, \e edits it when the buffer
+	// is empty.
+	lastInput string
+}
+
+// runREPL reads statements terminated by ; from the terminal and executes
+// them until \q or Ctrl-D. Like psql they run in autocommit mode on one
+// session, so SET and an explicit BEGIN carry over to the next input.
+func runREPL(ctx context.Context, connArgs connArgs, outArgs outputArgs, inArgs inputArgs) error {
+	connArgs, outArgs, err := resolveArgs(connArgs, outArgs)
+	if err != nil {
+		return err
+	}
+	parser, err := newScriptParser(inArgs)
+	if err != nil {
+		return err
+	}
+	connArgs.noTx = true
+	connArgs.singleConnection = true
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if err := pool.Ping(ctx); err != nil {
+		return err
+	}
+
+	history := historyFile()
+	if history != "" {
+		if err := compactHistory(history); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	r := &repl{pool: pool, connArgs: connArgs, outArgs: outArgs, parser: parser, history: history}
+	r.complete = &completer{pool: pool}
+	// Inputs are added to the history once complete, so a statement
+	// spanning several lines is recalled as a whole.
+	config := &readline.Config{
+		AutoComplete:           r.complete,
+		InterruptPrompt:        "^C",
+		HistoryLimit:           historyLimit,
+		HistorySearchFold:      true,
+		DisableAutoSaveHistory: true,
+	}
+	if outArgs.color {
+		config.Painter = sqlPainter{r}
+	}
+	rl, err := readline.NewEx(config)
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+	r.rl = rl
+	if history != "" {
+		inputs, err := loadHistory(history)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		for _, input := range inputs {
+			rl.SaveHistory(input)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "pgexec connected to %s, end statements with ; and quit with \\q\n", r.database())
+	for {
+		rl.SetPrompt(r.prompt(ctx))
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			r.buf.Reset()
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if r.buf.Len() == 0 {
+			switch trim(line) {
+			case "":
+				continue
+			case `\q`, "quit", "exit":
+				return nil
+			case `\refresh`:
+				r.complete.refresh()
+				continue
+			}
+		}
+		if trim(line) == `\e` {
+			r.edit(ctx)
+			continue
+		}
+		r.buf.WriteString(line + "\n")
+		if inputComplete(r.buf.String()) {
+			input := r.buf.String()
+			r.buf.Reset()
+			r.submit(ctx, input)
+		}
+	}
+}
+
+// submit executes a complete input and adds it to the history.
+func (r *repl) submit(ctx context.Context, input string) {
+	r.addHistory(input)
+	r.lastInput = input
+	if err := r.exec(ctx, input); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+	}
+}
+
+// edit opens the current buffer, or the last input if it's empty, in the
+// editor like \e in psql. A saved, complete statement is executed right
+// away, anything else stays in the buffer to continue typing.
+func (r *repl) edit(ctx context.Context) {
+	text := r.buf.String()
+	if text == "" {
+		text = r.lastInput
+	}
+	edited, saved, err := editText(text)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		return
+	}
+	r.buf.Reset()
+	if trim(edited) == "" {
+		return
+	}
+	edited = strings.TrimRight(edited, "\n") + "\n"
+	if saved && inputComplete(edited) {
+		r.submit(ctx, edited)
+		return
+	}
+	r.buf.WriteString(edited)
+	fmt.Fprint(os.Stderr, edited)
+}
+
+// exec runs the statements of one input. Ctrl-C cancels them without
+// leaving the REPL.
+func (r *repl) exec(ctx context.Context, input string) (err error) {
+	statements, err := r.parser.statements([]sqlScript{{sql: input}})
+	if err != nil || len(statements) == 0 {
+		return err
+	}
+	for _, stmt := range statements {
+		switch firstKeyword(stmt.sql) {
+		case "create", "alter", "drop":
+			r.complete.refresh()
+		}
+	}
+	if err := checkResultSets(r.connArgs, r.outArgs, statements); err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	out, err := openOutput(r.outArgs)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := closeOutput(out, err); err == nil {
+			err = closeErr
+		}
+	}()
+	return runStatements(ctx, r.pool, r.connArgs, out, r.outArgs, statements)
+}
+
+func (r *repl) addHistory(input string) {
+	entry := historyEntry(input)
+	if entry == r.lastEntry {
+		return
+	}
+	r.lastEntry = entry
+	r.rl.SaveHistory(trim(input))
+	if r.history == "" {
+		return
+	}
+	if err := appendHistory(r.history, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+func (r *repl) database() string {
+	if db := r.pool.Config().ConnConfig.Database; db != "" {
+		return db
+	}
+	return "pgexec"
+}
+
+// prompt follows psql: db=> for a new statement, db-> to continue one and
+// db=*> inside a transaction.
+func (r *repl) prompt(ctx context.Context) string {
+	if r.buf.Len() > 0 {
+		return r.database() + "-> "
+	}
+	if conn, err := r.pool.Acquire(ctx); err == nil {
+		defer conn.Release()
+		if conn.Conn().PgConn().TxStatus() != 'I' {
+			return r.database() + "=*> "
+		}
+	}
+	return r.database() + "=> "
+}
+
+// inputComplete reports whether the input ends with a terminated statement
+// or a meta-command, which run at the end of their line.
+func inputComplete(input string) bool {
+	lines := strings.Split(trim(input), "\n")
+	if strings.HasPrefix(trim(lines[len(lines)-1]), `\`) {
+		return true
+	}
+	complete := false
+	for _, seg := range scanSQL(input) {
+		switch seg.kind {
+		case codeSegment:
+			if s := trim(seg.text); s != "" {
+				complete = strings.HasSuffix(s, ";")
+			}
+		case quotedSegment:
+			complete = false
+		case commentSegment:
+			if strings.HasPrefix(seg.text, "/*") && !strings.HasSuffix(seg.text, "*/") {
+				complete = false
+			}
+		}
+	}
+	return complete
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// retryBackoff is the wait before the first retry, it doubles after every
+// further attempt.
+const retryBackoff = 100 * time.Millisecond
+
+// isRetryable reports whether err is a serialization failure or deadlock,
+// after which the transaction can simply be run again.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// execRetrying runs a transaction with execBatch and retries it up to
+// --retry-serializable times on serialization failures and deadlocks. The
+// output of an attempt is held back until it is known whether it's retried,
+// so failed attempts don't show up. Without a transaction, or with an
+// --on-error mode that commits part of it, nothing can be retried.
+func execRetrying(ctx context.Context, pool *pgxpool.Pool, connArgs connArgs, out, status io.Writer, outArgs outputArgs, statements []sqlStatement, verbose bool) error {
+	if connArgs.retrySerializable <= 0 || connArgs.noTx || connArgs.onError != "rollback" {
+		return execBatch(ctx, pool, connArgs, out, status, outArgs, statements, verbose)
+	}
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		var attemptOut, attemptStatus bytes.Buffer
+		err := execBatch(ctx, pool, connArgs, &attemptOut, &attemptStatus, outArgs, statements, verbose)
+		if err == nil || !isRetryable(err) || attempt == connArgs.retrySerializable {
+			status.Write(attemptStatus.Bytes())
+			if _, writeErr := out.Write(attemptOut.Bytes()); writeErr != nil {
+				return errors.Join(err, writeErr)
+			}
+			return err
+		}
+		// Jitter keeps concurrent pgexec runs from colliding again.
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		fmt.Fprintf(status, "warning: %v, retrying in %s (%d/%d)\n", err, wait.Round(time.Millisecond), attempt+1, connArgs.retrySerializable)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Upload uploads to an S3 object with the ambient AWS credentials. The
+// upload manager sends the output in parts as it is produced, so exports
+// larger than memory or the local disk work. Without a configured region
+// the region of the bucket is looked up.
+func s3Upload(bucket, key string) (func(context.Context, io.Reader) error, error) {
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Region == "" {
+		region, err := manager.GetBucketRegion(ctx, s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.Region = "us-east-1"
+		}), bucket)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Region = region
+	}
+	uploader := manager.NewUploader(s3.NewFromConfig(cfg))
+	return func(ctx context.Context, r io.Reader) error {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   r,
+		})
+		return err
+	}, nil
+}
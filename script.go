@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sqlStatement is a single statement of a script together with the
+// parameters bound to its placeholders and the file it was read from.
+type sqlStatement struct {
+	sql     string
+	args    []any
+	file    string
+	analyze bool
+	// input is the file COPY ... FROM STDIN reads, - for stdin.
+	input string
+}
+
+// namedParam is a --param name=value or --param name:type=value flag.
+type namedParam struct {
+	typ   string
+	value string
+}
+
+var namedParamPattern = regexp.MustCompile(`(?s)^([A-Za-z_][A-Za-z0-9_]*)(?::([A-Za-z_][A-Za-z0-9_ .]*(?:\[\])?))?=(.*)$`)
+
+// parseParams sorts the --param flags into positional values and named
+// parameters.
+func parseParams(params []string) ([]string, map[string]namedParam) {
+	var positional []string
+	named := map[string]namedParam{}
+	for _, p := range params {
+		m := namedParamPattern.FindStringSubmatch(p)
+		if m == nil {
+			positional = append(positional, p)
+			continue
+		}
+		named[m[1]] = namedParam{typ: trim(m[2]), value: m[3]}
+	}
+	return positional, named
+}
+
+// scriptStatements splits the scripts into statements and binds the
+// --param values to the placeholders $1..$n and :name references each
+// statement uses.
+func scriptStatements(scripts []sqlScript, inArgs inputArgs) ([]sqlStatement, error) {
+	p, err := newScriptParser(inArgs)
+	if err != nil {
+		return nil, err
+	}
+	return p.statements(scripts)
+}
+
+// scriptParser turns scripts into statements, keeping the variables set
+// by one script for the ones parsed after it.
+type scriptParser struct {
+	positional []string
+	named      map[string]namedParam
+	expander   *scriptExpander
+	copyInput  string
+}
+
+func newScriptParser(inArgs inputArgs) (*scriptParser, error) {
+	positional, named := parseParams(inArgs.params)
+	vars, err := parseVariables(inArgs.variables)
+	if err != nil {
+		return nil, err
+	}
+	e := &scriptExpander{inArgs: inArgs, vars: vars}
+	if inArgs.templateSQL {
+		if e.data, err = templateContext(inArgs.templateContext, vars); err != nil {
+			return nil, err
+		}
+	}
+	copyInput := inArgs.copyInput
+	if copyInput == "" {
+		copyInput = "-"
+	}
+	return &scriptParser{positional: positional, named: named, expander: e, copyInput: copyInput}, nil
+}
+
+func (p *scriptParser) statements(scripts []sqlScript) ([]sqlStatement, error) {
+	var statements []sqlStatement
+	for _, script := range scripts {
+		expanded, err := p.expander.expand(script.name, script.sql)
+		if err != nil {
+			return nil, err
+		}
+		for _, sql := range splitStatements(expanded) {
+			n := maxPlaceholder(sql)
+			if n > len(p.positional) {
+				return nil, fmt.Errorf("statement uses $%d but only %d positional --param given: %s", n, len(p.positional), sql)
+			}
+			args := make([]any, n)
+			for i := range args {
+				args[i] = p.positional[i]
+			}
+			sql, args = bindNamedParams(sql, p.named, args)
+			stmt := sqlStatement{sql: sql, args: args, file: script.name}
+			if copyDirection(sql) == "from" {
+				if script.stdin && p.copyInput == "-" {
+					return nil, errors.New("the SQL is read from stdin, pass the COPY data with --input")
+				}
+				stmt.input = p.copyInput
+			}
+			statements = append(statements, stmt)
+		}
+	}
+	return statements, nil
+}
+
+// scriptExpander preprocesses scripts and the files they include. Like in
+// psql, variables set in one script stay set for the following ones.
+type scriptExpander struct {
+	inArgs inputArgs
+	vars   map[string]string
+	data   map[string]any
+
+	// including is the chain of files being included, to detect cycles.
+	including []string
+}
+
+// expand applies --envsubst and --template-sql, runs the meta-commands of
+// a script and substitutes its variables. \set and \unset change the
+// variables for the rest of the script.
+func (e *scriptExpander) expand(name, sql string) (string, error) {
+	var err error
+	if e.inArgs.envsubst {
+		if sql, err = expandEnv(sql); err != nil {
+			return "", err
+		}
+	}
+	if e.inArgs.templateSQL {
+		if sql, err = executeSQLTemplate(name, sql, e.data); err != nil {
+			return "", err
+		}
+	}
+
+	var out strings.Builder
+	for _, part := range splitMetaCommands(sql) {
+		if !part.meta {
+			out.WriteString(substituteVariables(part.text, e.vars))
+			continue
+		}
+		cmd, args, _ := strings.Cut(part.text, " ")
+		args = trim(args)
+		switch cmd {
+		case `\set`:
+			name, value, _ := strings.Cut(args, " ")
+			if name == "" {
+				return "", fmt.Errorf("%s needs a variable name", cmd)
+			}
+			e.vars[name] = unquoteMetaArg(substituteVariables(trim(value), e.vars))
+		case `\unset`:
+			delete(e.vars, args)
+		case `\i`, `\include`, `\ir`, `\include_relative`:
+			included, err := e.include(name, unquoteMetaArg(substituteVariables(args, e.vars)))
+			if err != nil {
+				return "", err
+			}
+			// Keep the statement before the include apart from the
+			// included ones.
+			out.WriteString("\n;\n" + included + "\n;\n")
+		default:
+			query, ok := describeQuery(cmd, unquoteMetaArg(substituteVariables(args, e.vars)))
+			if !ok {
+				return "", fmt.Errorf("unsupported meta-command %s", cmd)
+			}
+			out.WriteString("\n;\n" + query + "\n;\n")
+		}
+	}
+	return out.String(), nil
+}
+
+// include reads and expands a file included by the script name. Relative
+// paths are resolved against the directory of the including file.
+func (e *scriptExpander) include(name, file string) (string, error) {
+	if file == "" {
+		return "", errors.New(`\i needs a file name`)
+	}
+	if name != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(name), file)
+	}
+	if slices.Contains(e.including, file) {
+		return "", fmt.Errorf("%s includes itself: %s", file, strings.Join(append(e.including, file), " -> "))
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	e.including = append(e.including, file)
+	defer func() { e.including = e.including[:len(e.including)-1] }()
+	return e.expand(file, string(b))
+}
+
+// bindNamedParams replaces :name references to named parameters with
+// placeholders following the positional ones. References to unknown names
+// are left alone, they may be array slices.
+func bindNamedParams(sql string, named map[string]namedParam, args []any) (string, []any) {
+	if len(named) == 0 {
+		return sql, args
+	}
+	placeholders := map[string]string{}
+	var out strings.Builder
+	for _, seg := range scanSQL(sql) {
+		if seg.kind != codeSegment {
+			out.WriteString(seg.text)
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			name := namedRef(s, i)
+			p, ok := named[name]
+			if name == "" || !ok {
+				out.WriteByte(s[i])
+				continue
+			}
+			placeholder, ok := placeholders[name]
+			if !ok {
+				args = append(args, p.value)
+				placeholder = "$" + strconv.Itoa(len(args))
+				if p.typ != "" {
+					placeholder += "::" + p.typ
+				}
+				placeholders[name] = placeholder
+			}
+			out.WriteString(placeholder)
+			i += len(name)
+		}
+	}
+	return out.String(), args
+}
+
+// namedRef returns the name of a :name reference at s[i], ignoring casts
+// like ::int.
+func namedRef(s string, i int) string {
+	if s[i] != ':' || i > 0 && s[i-1] == ':' || i+1 < len(s) && s[i+1] == ':' {
+		return ""
+	}
+	j := i + 1
+	for j < len(s) && isIdentByte(s[j]) && s[j] != '$' {
+		j++
+	}
+	if j == i+1 || s[i+1] >= '0' && s[i+1] <= '9' {
+		return ""
+	}
+	return s[i+1 : j]
+}
+
+// maxPlaceholder returns the highest $n placeholder outside of quotes and
+// comments.
+func maxPlaceholder(sql string) int {
+	highest := 0
+	for _, seg := range scanSQL(sql) {
+		if seg.kind != codeSegment {
+			continue
+		}
+		s := seg.text
+		for i := 0; i < len(s); i++ {
+			if s[i] != '$' || i > 0 && isIdentByte(s[i-1]) {
+				continue
+			}
+			j := i + 1
+			for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				j++
+			}
+			if n, err := strconv.Atoi(s[i+1 : j]); err == nil {
+				highest = max(highest, n)
+			}
+			i = j - 1
+		}
+	}
+	return highest
+}
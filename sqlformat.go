@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// sqlWriter emits one INSERT statement per row.
+type sqlWriter struct {
+	out     *bufio.Writer
+	values  *valueFormat
+	table   string
+	fields  []pgconn.FieldDescription
+	columns string
+}
+
+// inferTable guesses the target table of the sql output from the FROM
+// clause of the query. Only FROM outside of parentheses counts, so
+// extract(year FROM ts) or subqueries don't match. It's empty when the
+// rows may come from several tables, like with joins, CTEs or subqueries
+// in FROM.
+func inferTable(sql string) string {
+	if firstKeyword(sql) == "with" {
+		return ""
+	}
+	tokens := topLevelTokens(sql)
+	table := ""
+	for i := 0; i < len(tokens); i++ {
+		if !strings.EqualFold(tokens[i], "from") {
+			continue
+		}
+		name, n := qualifiedName(tokens[i+1:])
+		if name == "" {
+			return ""
+		}
+		i += n
+	clause:
+		for _, tok := range tokens[i+1:] {
+			switch strings.ToLower(tok) {
+			case ",", "(", "join", "natural", "cross", "inner", "left", "right", "full":
+				return ""
+			case "where", "group", "having", "window", "order", "limit", "offset", "fetch", "for", "returning", "union", "intersect", "except", ";":
+				break clause
+			}
+		}
+		if table != "" && table != name {
+			return ""
+		}
+		table = name
+	}
+	return table
+}
+
+// qualifiedName returns the possibly schema qualified table name at the
+// start of tokens and the number of tokens it spans.
+func qualifiedName(tokens []string) (string, int) {
+	n := 0
+	if n < len(tokens) && strings.EqualFold(tokens[n], "only") {
+		n++
+	}
+	if n >= len(tokens) || !isIdentToken(tokens[n]) {
+		return "", 0
+	}
+	name := tokens[n]
+	n++
+	if n+1 < len(tokens) && tokens[n] == "." && isIdentToken(tokens[n+1]) {
+		name += "." + tokens[n+1]
+		n += 2
+	}
+	return name, n
+}
+
+func isIdentToken(tok string) bool {
+	c := tok[0]
+	return c == '"' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// topLevelTokens splits the SQL outside of parentheses into words, quoted
+// identifiers, string literals and punctuation. Comments are dropped and
+// a parenthesized expression is represented by its opening parenthesis.
+func topLevelTokens(sql string) []string {
+	var tokens []string
+	depth := 0
+	for _, seg := range scanSQL(sql) {
+		switch seg.kind {
+		case commentSegment:
+			continue
+		case quotedSegment:
+			if depth == 0 {
+				tokens = append(tokens, seg.text)
+			}
+			continue
+		}
+		text := seg.text
+		for i := 0; i < len(text); {
+			c := text[i]
+			switch {
+			case isIdentByte(c):
+				end := i + 1
+				for end < len(text) && isIdentByte(text[end]) {
+					end++
+				}
+				if depth == 0 {
+					tokens = append(tokens, text[i:end])
+				}
+				i = end
+				continue
+			case c == '(':
+				if depth == 0 {
+					tokens = append(tokens, "(")
+				}
+				depth++
+			case c == ')':
+				if depth > 0 {
+					depth--
+				}
+			case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			default:
+				if depth == 0 {
+					tokens = append(tokens, string(c))
+				}
+			}
+			i++
+		}
+	}
+	return tokens
+}
+
+func newSQLWriter(out *bufio.Writer, values *valueFormat, table string) (*sqlWriter, error) {
+	if trim(table) == "" {
+		return nil, errors.New("could not infer the table name for sql output, use --target-table")
+	}
+	return &sqlWriter{out: out, values: values, table: trim(table)}, nil
+}
+
+func (w *sqlWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = pgx.Identifier{f.Name}.Sanitize()
+	}
+	w.columns = strings.Join(names, ", ")
+	return nil
+}
+
+func (w *sqlWriter) WriteRow(values []any) error {
+	w.out.WriteString("INSERT INTO ")
+	w.out.WriteString(w.table)
+	w.out.WriteString(" (")
+	w.out.WriteString(w.columns)
+	w.out.WriteString(") VALUES (")
+	for i, v := range values {
+		if i > 0 {
+			w.out.WriteString(", ")
+		}
+		w.out.WriteString(w.literal(w.fields[i], v))
+	}
+	_, err := w.out.WriteString(");\n")
+	return err
+}
+
+// literal renders a scanned value as a SQL literal that Postgres casts
+// back to the column type on insert.
+func (w *sqlWriter) literal(field pgconn.FieldDescription, v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if val {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int16, int32, int64:
+		return w.values.text(field, v)
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return quoteLiteral(w.values.text(field, v))
+		}
+		return w.values.text(field, v)
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return quoteLiteral(w.values.text(field, v))
+		}
+		return w.values.text(field, v)
+	case pgtype.Numeric:
+		if val.NaN || val.InfinityModifier != pgtype.Finite {
+			return quoteLiteral(exactNumeric.format(val))
+		}
+		return exactNumeric.format(val)
+	case []byte:
+		return `'\x` + hex.EncodeToString(val) + `'`
+	case time.Time:
+		if field.DataTypeOID == pgtype.DateOID {
+			return quoteLiteral(val.Format("2006-01-02"))
+		}
+		return quoteLiteral(val.Format(time.RFC3339Nano))
+	case []any:
+		// An untyped array literal is cast to the column type on insert,
+		// ARRAY[...] of string literals would be text[].
+		return quoteLiteral(w.arrayLiteral(field, val))
+	}
+	return quoteLiteral(w.values.text(field, v))
+}
+
+// arrayLiteral renders an array as Postgres array literal like {a,b,NULL},
+// with the elements written like the literals of scalar values.
+func (w *sqlWriter) arrayLiteral(field pgconn.FieldDescription, arr []any) string {
+	elem, _ := elementField(field)
+	var s strings.Builder
+	s.WriteByte('{')
+	for i, v := range arr {
+		if i > 0 {
+			s.WriteByte(',')
+		}
+		switch val := v.(type) {
+		case nil:
+			s.WriteString("NULL")
+		case []any:
+			s.WriteString(w.arrayLiteral(field, val))
+		default:
+			lit := w.literal(elem, v)
+			if strings.HasPrefix(lit, "'") {
+				lit = strings.ReplaceAll(lit[1:len(lit)-1], "''", "'")
+			}
+			s.WriteString(quoteArrayElement(lit))
+		}
+	}
+	s.WriteByte('}')
+	return s.String()
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (w *sqlWriter) Close() error {
+	return w.out.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestInferTable(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM orders", "orders"},
+		{"SELECT * FROM public.orders WHERE id IN (SELECT id FROM other)", "public.orders"},
+		{`SELECT * FROM "My Table" t ORDER BY a, b`, `"My Table"`},
+		{"SELECT extract(year FROM ts) FROM orders", "orders"},
+		{"SELECT substring(x FROM 2), trim(both FROM y) FROM orders", "orders"},
+		{"SELECT 'from x' FROM /* from y */ orders -- from z", "orders"},
+		{"SELECT * FROM ONLY orders", "orders"},
+		{"SELECT * FROM a UNION ALL SELECT * FROM a", "a"},
+		{"SELECT extract(year FROM ts)", ""},
+		{"SELECT * FROM a JOIN b USING (id)", ""},
+		{"SELECT * FROM a, b", ""},
+		{"SELECT * FROM a UNION SELECT * FROM b", ""},
+		{"SELECT * FROM (SELECT 1) s", ""},
+		{"SELECT * FROM generate_series(1, 3)", ""},
+		{"WITH t AS (SELECT * FROM a) SELECT * FROM t", ""},
+	}
+	for _, tt := range tests {
+		if got := inferTable(tt.sql); got != tt.want {
+			t.Errorf("inferTable(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSQLWriterArrays(t *testing.T) {
+	fields := []pgconn.FieldDescription{
+		{Name: "days", DataTypeOID: pgtype.DateArrayOID},
+		{Name: "tags", DataTypeOID: pgtype.TextArrayOID},
+	}
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	got := render(t, outputArgs{format: "sql", targetTable: "t"}, fields,
+		[]any{[]any{day, nil}, []any{"it's", "a b"}},
+		[]any{[]any{}, []any{[]any{"x"}, []any{"y"}}},
+	)
+	want := `INSERT INTO t ("days", "tags") VALUES ('{2024-01-02,NULL}', '{it''s,"a b"}');
+INSERT INTO t ("days", "tags") VALUES ('{}', '{{x},{y}}');
+`
+	if got != want {
+		t.Errorf("sql output = %q, want %q", got, want)
+	}
+}
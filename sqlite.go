@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/urfave/cli/v2"
+	_ "modernc.org/sqlite"
+)
+
+func exportSQLiteCommand(args *connArgs) *cli.Command {
+	var output, table string
+	return &cli.Command{
+		Name:      "export-sqlite",
+		Usage:     "Write the result of a query into a table of a SQLite database",
+		UsageText: "pgexec export-sqlite --url \"postgres://...\" --output data.db --table result \"SELECT ...\"",
+		Flags: append(connFlags(args),
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Destination: &output,
+				Required:    true,
+				Usage:       "SQLite database file, created if it doesn't exist",
+			},
+			&cli.StringFlag{
+				Name:        "table",
+				Value:       "result",
+				Destination: &table,
+				Usage:       "Table to create for the result",
+			},
+		),
+		Action: func(cCtx *cli.Context) error {
+			if err := applyProfile(cCtx, args.profile); err != nil {
+				return err
+			}
+			if err := loadEnvFile(args); err != nil {
+				return err
+			}
+			scripts, err := readSQL(inputArgs{}, cCtx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			statements, err := scriptStatements(scripts, inputArgs{})
+			if err != nil {
+				return err
+			}
+			if len(statements) != 1 {
+				return errors.New("pass a single query to export")
+			}
+			return exportSQLite(cCtx.Context, *args, statements[0], output, table)
+		},
+	}
+}
+
+// exportSQLite runs a query and writes its result set into a new table of
+// a SQLite database, as a snapshot that can be queried without a server.
+// The query runs in a transaction unless --no-transaction is given, which
+// is only committed once all rows are in SQLite, so a failed export
+// doesn't keep the writes of a query with RETURNING.
+func exportSQLite(ctx context.Context, connArgs connArgs, stmt sqlStatement, output, table string) error {
+	txOptions, err := transactionOptions(connArgs)
+	if err != nil {
+		return err
+	}
+	values, err := newValueFormat(outputArgs{})
+	if err != nil {
+		return err
+	}
+	pool, err := getConnPool(ctx, connArgs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	var ex Executor = pool
+	var tx pgx.Tx
+	if !connArgs.noTx {
+		if tx, err = pool.BeginTx(ctx, txOptions); err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		ex = tx
+	}
+	rows, err := ex.Query(ctx, stmt.sql, stmt.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	w := &sqliteWriter{ctx: ctx, path: output, table: table, values: values}
+	defer w.finish()
+	if err := writeRows(w, rows); err != nil {
+		return err
+	}
+	rows.Close()
+	if tx != nil {
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	if err := w.commit(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, rows.CommandTag())
+	return nil
+}
+
+// sqliteType returns the column type for a Postgres type, the one whose
+// affinity keeps the values comparable and sortable in SQLite.
+func sqliteType(oid uint32) string {
+	switch oid {
+	case pgtype.BoolOID, pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID, pgtype.OIDOID:
+		return "INTEGER"
+	case pgtype.Float4OID, pgtype.Float8OID:
+		return "REAL"
+	case pgtype.NumericOID:
+		return "NUMERIC"
+	case pgtype.ByteaOID:
+		return "BLOB"
+	}
+	return "TEXT"
+}
+
+// sqliteWriter inserts the rows of a result set into a new SQLite table,
+// in one transaction. Values without a SQLite counterpart, like
+// timestamps, arrays and json, are stored as text like in CSV output. The
+// database is only opened once the query returned its columns, so a
+// failing connection or query doesn't create an empty file.
+type sqliteWriter struct {
+	ctx     context.Context
+	path    string
+	db      *sql.DB
+	created bool
+	done    bool
+	table   string
+	values  *valueFormat
+	fields  []pgconn.FieldDescription
+	tx      *sql.Tx
+	insert  *sql.Stmt
+}
+
+func sqliteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// sqliteColumnNames makes the column names of a result set unique, as
+// Postgres allows duplicates like the ?column? of SELECT 1, 2 or the id of
+// both sides of a join. Later duplicates get a _2, _3, ... suffix. SQLite
+// compares names case insensitively.
+func sqliteColumnNames(fields []pgconn.FieldDescription) []string {
+	names := make([]string, len(fields))
+	seen := map[string]bool{}
+	for i, f := range fields {
+		name := f.Name
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", f.Name, n)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func (w *sqliteWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	if len(fields) == 0 {
+		return errors.New("the statement returns no columns, export a query")
+	}
+	w.fields = fields
+	columns := make([]string, len(fields))
+	placeholders := make([]string, len(fields))
+	for i, name := range sqliteColumnNames(fields) {
+		columns[i] = sqliteIdent(name) + " " + sqliteType(fields[i].DataTypeOID)
+		placeholders[i] = "?"
+	}
+	_, err := os.Stat(w.path)
+	w.created = os.IsNotExist(err)
+	if w.db, err = sql.Open("sqlite", w.path); err != nil {
+		return err
+	}
+	if w.tx, err = w.db.BeginTx(w.ctx, nil); err != nil {
+		return err
+	}
+	var exists bool
+	if err := w.tx.QueryRowContext(w.ctx, "SELECT count(*) > 0 FROM sqlite_master WHERE name = ? COLLATE NOCASE", w.table).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("table %s already exists in %s, pick another with --table", sqliteIdent(w.table), w.path)
+	}
+	if _, err := w.tx.ExecContext(w.ctx, fmt.Sprintf("CREATE TABLE %s (%s)", sqliteIdent(w.table), strings.Join(columns, ", "))); err != nil {
+		return err
+	}
+	w.insert, err = w.tx.PrepareContext(w.ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)", sqliteIdent(w.table), strings.Join(placeholders, ", ")))
+	return err
+}
+
+func (w *sqliteWriter) WriteRow(values []any) error {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = w.sqliteValue(w.fields[i], v)
+	}
+	_, err := w.insert.ExecContext(w.ctx, args...)
+	return err
+}
+
+func (w *sqliteWriter) sqliteValue(field pgconn.FieldDescription, v any) any {
+	switch val := v.(type) {
+	case nil, bool, int64, float64, []byte:
+		return v
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case float32:
+		return float64(val)
+	}
+	return w.values.text(field, v)
+}
+
+// Close only ends the inserts, the table is committed by commit once the
+// query is committed too.
+func (w *sqliteWriter) Close() error {
+	return w.insert.Close()
+}
+
+func (w *sqliteWriter) commit() error {
+	err := w.tx.Commit()
+	w.tx = nil
+	w.done = err == nil
+	return err
+}
+
+// finish closes the database. A failed export is rolled back, so no
+// partial table is left behind, and a database file it created is removed.
+func (w *sqliteWriter) finish() {
+	if w.db == nil {
+		return
+	}
+	if w.tx != nil {
+		w.tx.Rollback()
+	}
+	w.db.Close()
+	if !w.done && w.created {
+		os.Remove(w.path)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSQLiteColumnNames(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{[]string{"id", "name"}, []string{"id", "name"}},
+		{[]string{"?column?", "?column?"}, []string{"?column?", "?column?_2"}},
+		{[]string{"id", "ID", "id"}, []string{"id", "ID_2", "id_3"}},
+		{[]string{"id", "id_2", "id"}, []string{"id", "id_2", "id_3"}},
+		{[]string{"id", "ID", "id_2"}, []string{"id", "ID_2", "id_2_2"}},
+	}
+	for _, tt := range tests {
+		fields := make([]pgconn.FieldDescription, len(tt.names))
+		for i, name := range tt.names {
+			fields[i].Name = name
+		}
+		if got := sqliteColumnNames(fields); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sqliteColumnNames(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteWriterRejectsNoColumns(t *testing.T) {
+	w := &sqliteWriter{}
+	if err := w.WriteHeader(nil); err == nil {
+		t.Error("WriteHeader without columns succeeded, want an error")
+	}
+}
+
+func TestSQLiteWriterFiles(t *testing.T) {
+	values, err := newValueFormat(outputArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data.db")
+	export := func() (*sqliteWriter, error) {
+		w := &sqliteWriter{ctx: context.Background(), path: path, table: "result", values: values}
+		return w, w.WriteHeader(testFields)
+	}
+
+	// A failed export removes the database it created.
+	w, err := export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.finish()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("failed export left %s behind: %v", path, err)
+	}
+
+	w, err = export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]any{int64(1), "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.commit(); err != nil {
+		t.Fatal(err)
+	}
+	w.finish()
+
+	// The table exists now, the error names it and the file is kept.
+	w, err = export()
+	if err == nil || !strings.Contains(err.Error(), `"result"`) {
+		t.Errorf("second export = %v, want an error naming the table", err)
+	}
+	w.finish()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("failed export removed the existing database: %v", err)
+	}
+}
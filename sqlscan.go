@@ -0,0 +1,290 @@
+package main
+
+import "strings"
+
+type segmentKind int
+
+const (
+	codeSegment segmentKind = iota
+	quotedSegment
+	commentSegment
+)
+
+// sqlSegment is a piece of SQL text. Only code segments can contain
+// statement separators, placeholders or variables, everything in string
+// literals, quoted identifiers and comments is taken literally.
+type sqlSegment struct {
+	kind segmentKind
+	text string
+}
+
+// scanSQL splits SQL into code, quoted and comment segments. It knows
+// about standard and escape string literals, quoted identifiers, dollar
+// quoting and nested block comments. Unterminated quotes and comments run
+// to the end of the input.
+func scanSQL(sql string) []sqlSegment {
+	var segments []sqlSegment
+	start := 0
+	emit := func(kind segmentKind, end int) {
+		if end > start {
+			segments = append(segments, sqlSegment{kind: kind, text: sql[start:end]})
+		}
+		start = end
+	}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			escapes := i > 0 && (sql[i-1] == 'e' || sql[i-1] == 'E') && (i < 2 || !isIdentByte(sql[i-2]))
+			if escapes {
+				i--
+			}
+			emit(codeSegment, i)
+			if escapes {
+				i++
+			}
+			i = quoteEnd(sql, i+1, '\'', escapes)
+			emit(quotedSegment, i)
+		case c == '"':
+			emit(codeSegment, i)
+			i = quoteEnd(sql, i+1, '"', false)
+			emit(quotedSegment, i)
+		case c == '$' && (i == 0 || !isIdentByte(sql[i-1])):
+			tag, ok := dollarTag(sql[i:])
+			if !ok {
+				i++
+				continue
+			}
+			emit(codeSegment, i)
+			if end := strings.Index(sql[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag)
+			} else {
+				i = len(sql)
+			}
+			emit(quotedSegment, i)
+		case strings.HasPrefix(sql[i:], "--"):
+			emit(codeSegment, i)
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+			emit(commentSegment, i)
+		case strings.HasPrefix(sql[i:], "/*"):
+			emit(codeSegment, i)
+			i = blockCommentEnd(sql, i+2)
+			emit(commentSegment, i)
+		default:
+			i++
+		}
+	}
+	emit(codeSegment, len(sql))
+	return segments
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// quoteEnd returns the index after the closing quote, quotes are escaped
+// by doubling them or with a backslash in escape strings.
+func quoteEnd(sql string, i int, quote byte, escapes bool) int {
+	for i < len(sql) {
+		switch {
+		case escapes && sql[i] == '\\':
+			i += 2
+		case sql[i] == quote && i+1 < len(sql) && sql[i+1] == quote:
+			i += 2
+		case sql[i] == quote:
+			return i + 1
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// dollarTag returns the opening tag of a dollar quoted string like $$ or
+// $body$ at the start of s. $1 is a placeholder, not a tag.
+func dollarTag(s string) (string, bool) {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1], true
+		case c >= '0' && c <= '9':
+			if i == 1 {
+				return "", false
+			}
+		case !isIdentByte(c):
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func blockCommentEnd(sql string, i int) int {
+	depth := 1
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// splitStatements splits a script at the semicolons outside of quotes and
+// comments. Statements consisting only of whitespace and comments are
+// dropped.
+func splitStatements(sql string) []string {
+	var (
+		statements []string
+		stmt       strings.Builder
+		empty      = true
+	)
+	flush := func() {
+		if !empty {
+			statements = append(statements, trim(stmt.String()))
+		}
+		stmt.Reset()
+		empty = true
+	}
+	for _, seg := range scanSQL(sql) {
+		if seg.kind != codeSegment {
+			stmt.WriteString(seg.text)
+			if seg.kind == quotedSegment {
+				empty = false
+			}
+			continue
+		}
+		parts := strings.Split(seg.text, ";")
+		for i, part := range parts {
+			if i > 0 {
+				flush()
+			}
+			stmt.WriteString(part)
+			if trim(part) != "" {
+				empty = false
+			}
+		}
+	}
+	flush()
+	return statements
+}
+
+// scriptPart is either SQL text or a psql style meta-command line like
+// \set, which starts with a backslash outside of quotes and comments.
+type scriptPart struct {
+	meta bool
+	text string
+}
+
+// includeComment is an alternative to \i that keeps scripts runnable by
+// other tools.
+const includeComment = "-- pgexec:include "
+
+// splitMetaCommands cuts the meta-command lines out of a script. The rest
+// of the script is scanned again after every meta-command, so quotes in
+// their arguments don't leak into the SQL that follows.
+func splitMetaCommands(sql string) []scriptPart {
+	offset := 0
+	for _, seg := range scanSQL(sql) {
+		n := len(seg.text)
+		switch seg.kind {
+		case quotedSegment:
+			n = 0
+		case commentSegment:
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			pos := offset + i
+			if pos > 0 && sql[pos-1] != '\n' {
+				continue
+			}
+			line, rest := sql[pos:], ""
+			if end := strings.IndexByte(line, '\n'); end >= 0 {
+				line, rest = line[:end+1], line[end+1:]
+			}
+			cmd := strings.TrimLeft(line, " \t")
+			if strings.HasPrefix(cmd, includeComment) {
+				cmd = `\i ` + cmd[len(includeComment):]
+			} else if !strings.HasPrefix(cmd, `\`) {
+				continue
+			}
+			var parts []scriptPart
+			if pos > 0 {
+				parts = append(parts, scriptPart{text: sql[:pos]})
+			}
+			parts = append(parts, scriptPart{meta: true, text: trim(cmd)})
+			return append(parts, splitMetaCommands(rest)...)
+		}
+		offset += len(seg.text)
+	}
+	if sql == "" {
+		return nil
+	}
+	return []scriptPart{{text: sql}}
+}
+
+// firstKeyword returns the lower cased first word of a statement, skipping
+// comments and opening parentheses.
+func firstKeyword(sql string) string {
+	for _, seg := range scanSQL(sql) {
+		switch seg.kind {
+		case commentSegment:
+			continue
+		case quotedSegment:
+			return ""
+		}
+		s := strings.TrimLeft(seg.text, " \t\r\n(")
+		if s == "" {
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return r >= 0x80 || !isIdentByte(byte(r)) })
+		if end < 0 {
+			end = len(s)
+		}
+		return strings.ToLower(s[:end])
+	}
+	return ""
+}
+
+// rowKeywords are the first keywords of statements that return a result
+// set.
+var rowKeywords = map[string]bool{
+	"select": true, "with": true, "values": true, "table": true,
+	"show": true, "explain": true, "fetch": true,
+}
+
+// returnsRows reports whether a statement returns a result set, judged by
+// its first keyword or a RETURNING clause.
+func returnsRows(sql string) bool {
+	return rowKeywords[firstKeyword(sql)] || hasKeyword(sql, "returning")
+}
+
+// hasKeyword reports whether the lower case keyword appears as a word
+// outside of quotes and comments.
+func hasKeyword(sql, keyword string) bool {
+	for _, seg := range scanSQL(sql) {
+		if seg.kind != codeSegment {
+			continue
+		}
+		words := strings.FieldsFunc(seg.text, func(r rune) bool { return r < 0x80 && !isIdentByte(byte(r)) })
+		for _, word := range words {
+			if strings.EqualFold(word, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
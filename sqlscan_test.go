@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []sqlSegment
+	}{
+		{"SELECT 1", []sqlSegment{{codeSegment, "SELECT 1"}}},
+		{"SELECT 'a;b'", []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, "'a;b'"}}},
+		{"SELECT 'it''s'", []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, "'it''s'"}}},
+		{`SELECT E'a\'b', 1`, []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, `E'a\'b'`}, {codeSegment, ", 1"}}},
+		{`SELECT "a""b"`, []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, `"a""b"`}}},
+		{"SELECT $$a;b$$", []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, "$$a;b$$"}}},
+		{"SELECT $fn$ $$ $fn$", []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, "$fn$ $$ $fn$"}}},
+		{"SELECT $1, a$b$", []sqlSegment{{codeSegment, "SELECT $1, a$b$"}}},
+		{"SELECT 1 -- c\n", []sqlSegment{{codeSegment, "SELECT 1 "}, {commentSegment, "-- c"}, {codeSegment, "\n"}}},
+		{"/* a /* b */ c */ SELECT", []sqlSegment{{commentSegment, "/* a /* b */ c */"}, {codeSegment, " SELECT"}}},
+		{"SELECT 'open", []sqlSegment{{codeSegment, "SELECT "}, {quotedSegment, "'open"}}},
+	}
+	for _, tt := range tests {
+		if got := scanSQL(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scanSQL(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT ';'; SELECT \";\"", []string{"SELECT ';'", `SELECT ";"`}},
+		{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;", []string{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql"}},
+		{"SELECT 1; -- done;\n", []string{"SELECT 1"}},
+		{"/* a; /* b; */ c; */ SELECT 1", []string{"/* a; /* b; */ c; */ SELECT 1"}},
+		{" ;; ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMetaCommands(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []scriptPart
+	}{
+		{"SELECT 1;", []scriptPart{{text: "SELECT 1;"}}},
+		{"\\set x 1\nSELECT :x;", []scriptPart{{meta: true, text: `\set x 1`}, {text: "SELECT :x;"}}},
+		{"SELECT 1;\n  \\i other.sql\nSELECT 2;", []scriptPart{{text: "SELECT 1;\n"}, {meta: true, text: `\i other.sql`}, {text: "SELECT 2;"}}},
+		{"-- pgexec:include other.sql\n", []scriptPart{{meta: true, text: `\i other.sql`}}},
+		{"SELECT '\n\\set x 1';", []scriptPart{{text: "SELECT '\n\\set x 1';"}}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitMetaCommands(tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitMetaCommands(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestFirstKeyword(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT 1", "select"},
+		{"  -- comment\n/* block */ Insert INTO t", "insert"},
+		{"((select 1))", "select"},
+		{"WITH x AS (SELECT 1) SELECT 1", "with"},
+		{"'text'", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := firstKeyword(tt.sql); got != tt.want {
+			t.Errorf("firstKeyword(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT 1", true},
+		{"(SELECT 1) UNION (SELECT 2)", true},
+		{"WITH t AS (SELECT 1) SELECT * FROM t", true},
+		{"INSERT INTO t VALUES (1) RETURNING id", true},
+		{"INSERT INTO t VALUES (1)", false},
+		{"UPDATE t SET note = 'returning'", false},
+		{"DELETE FROM t -- returning", false},
+		{"SET search_path = app", false},
+	}
+	for _, tt := range tests {
+		if got := returnsRows(tt.sql); got != tt.want {
+			t.Errorf("returnsRows(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
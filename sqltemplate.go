@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// sqlTemplateFuncs are available in --template-sql in addition to the
+// functions of the template output format.
+var sqlTemplateFuncs = template.FuncMap{
+	"seq":     seq,
+	"literal": quoteLiteral,
+	"ident": func(parts ...string) string {
+		return pgx.Identifier(parts).Sanitize()
+	},
+}
+
+// templateContext merges the --template-context JSON files and the --set
+// variables into the data passed to --template-sql.
+func templateContext(files []string, vars map[string]string) (map[string]any, error) {
+	data := map[string]any{}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]any
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for k, v := range values {
+			data[k] = v
+		}
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// executeSQLTemplate runs a script through text/template. Missing keys are
+// an error so typos don't end up as <no value> in the SQL.
+func executeSQLTemplate(name, sql string, data map[string]any) (string, error) {
+	if name == "" {
+		name = "sql"
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Funcs(sqlTemplateFuncs).Option("missingkey=error").Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// seq returns the integers from 1 to n, or from start to end, for ranging
+// over in templates. Numbers from JSON and --set are converted.
+func seq(bounds ...any) ([]int, error) {
+	ints := make([]int, len(bounds))
+	for i, b := range bounds {
+		n, err := toInt(b)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = n
+	}
+	start, end := 1, 0
+	switch len(ints) {
+	case 1:
+		end = ints[0]
+	case 2:
+		start, end = ints[0], ints[1]
+	default:
+		return nil, fmt.Errorf("seq takes one or two arguments, got %d", len(ints))
+	}
+	var s []int
+	for i := start; i <= end; i++ {
+		s = append(s, i)
+	}
+	return s, nil
+}
+
+func toInt(v any) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case float64:
+		return int(n), nil
+	case string:
+		return strconv.Atoi(n)
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultSSHKeys are tried when no --ssh-key is given, like ssh does.
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshTunnel connects to the --ssh jump host and dials Postgres through it.
+// Host names are resolved on the jump host, as the database is often only
+// known there. The tunnel lives as long as the process.
+func sshTunnel(config *pgxpool.Config, connArgs connArgs) error {
+	clientConfig, addr, err := sshClientConfig(connArgs)
+	if err != nil {
+		return err
+	}
+	// --keepalive applies to the connection to the SSH server, which
+	// carries the tunneled ones.
+	dialer := net.Dialer{Timeout: clientConfig.Timeout, KeepAlive: connArgs.keepalive}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("ssh %s: %w", connArgs.ssh, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("ssh %s: %w", connArgs.ssh, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	cc := config.ConnConfig
+	cc.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+	cc.DialFunc = func(_ context.Context, network, addr string) (net.Conn, error) {
+		return client.Dial(network, addr)
+	}
+	return nil
+}
+
+// sshClientConfig parses --ssh user@host[:port] and sets up key and agent
+// authentication. The host key is checked against ~/.ssh/known_hosts.
+func sshClientConfig(connArgs connArgs) (*ssh.ClientConfig, string, error) {
+	spec := connArgs.ssh
+	var name string
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		name, spec = spec[:at], spec[at+1:]
+	} else if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	addr := spec
+	if _, _, err := net.SplitHostPort(spec); err != nil {
+		addr = net.JoinHostPort(spec, "22")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, "", err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, "", fmt.Errorf("ssh: reading known_hosts: %w", err)
+	}
+
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+	keys := []string{connArgs.sshKey}
+	if connArgs.sshKey == "" {
+		keys = nil
+		for _, k := range defaultSSHKeys {
+			keys = append(keys, filepath.Join(home, ".ssh", k))
+		}
+	}
+	for _, k := range keys {
+		signer, err := sshSigner(k)
+		if err != nil {
+			if connArgs.sshKey == "" && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, "", err
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, "", errors.New("ssh: no keys found, start ssh-agent or pass --ssh-key")
+	}
+
+	return &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeys,
+	}, addr, nil
+}
+
+// sshSigner reads a private key, prompting for the passphrase if it is
+// encrypted.
+func sshSigner(path string) (ssh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	passphrase, err := readSecret(fmt.Sprintf("Enter passphrase for key '%s': ", path))
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKeyWithPassphrase(b, []byte(passphrase))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// templateWriter renders rows with a user supplied text/template. The
+// template is executed once per row with the columns as map keys, or once
+// for the whole result with .Columns and .Rows in aggregate mode.
+type templateWriter struct {
+	out       *bufio.Writer
+	values    *valueFormat
+	tmpl      *template.Template
+	aggregate bool
+	fields    []pgconn.FieldDescription
+	columns   []string
+	rows      []map[string]any
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+}
+
+// templateEscaper interprets the escapes people write in shell quoted
+// templates, e.g. '{{.id}}\t{{.email}}\n'.
+var templateEscaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t", `\r`, "\r")
+
+func newTemplateWriter(out *bufio.Writer, values *valueFormat, outArgs outputArgs) (*templateWriter, error) {
+	src := templateEscaper.Replace(outArgs.template)
+	if outArgs.templateFile != "" {
+		b, err := os.ReadFile(outArgs.templateFile)
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	}
+	if src == "" {
+		return nil, errors.New("template output needs --template or --template-file")
+	}
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &templateWriter{out: out, values: values, tmpl: tmpl, aggregate: outArgs.templateAggregate}, nil
+}
+
+func (w *templateWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	for _, f := range fields {
+		w.columns = append(w.columns, f.Name)
+	}
+	return nil
+}
+
+func (w *templateWriter) WriteRow(values []any) error {
+	row := make(map[string]any, len(values))
+	for i, v := range values {
+		row[w.fields[i].Name] = w.templateValue(w.fields[i], v)
+	}
+	if w.aggregate {
+		w.rows = append(w.rows, row)
+		return nil
+	}
+	return w.tmpl.Execute(w.out, row)
+}
+
+// templateValue keeps booleans and numbers usable in template conditions
+// and comparisons and renders everything else as text. NULL becomes an
+// empty string unless --null is given.
+func (w *templateWriter) templateValue(field pgconn.FieldDescription, v any) any {
+	switch v.(type) {
+	case nil:
+		return w.values.nullString("")
+	case bool, int16, int32, int64, float32, float64:
+		return v
+	}
+	return w.values.text(field, v)
+}
+
+func (w *templateWriter) Close() error {
+	if w.aggregate {
+		err := w.tmpl.Execute(w.out, map[string]any{
+			"Columns": w.columns,
+			"Rows":    w.rows,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// strftimeLayouts maps strftime conversions to their Go layout equivalents.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'f': "000000", 'p': "PM",
+	'z': "-0700", 'Z': "MST", 'F': "2006-01-02", 'T': "15:04:05",
+	'D': "01/02/06", 'R': "15:04",
+}
+
+// timeLayout is a parsed --time-format. Go reads words and digits like Mon
+// or 1 as layout fields, so the text around strftime conversions is kept
+// apart as literal and only the conversions are formatted by Go.
+type timeLayout []timeLayoutPart
+
+type timeLayoutPart struct {
+	text    string
+	literal bool
+}
+
+func (l timeLayout) format(t time.Time) string {
+	var s strings.Builder
+	for _, p := range l {
+		if p.literal {
+			s.WriteString(p.text)
+		} else {
+			s.WriteString(t.Format(p.text))
+		}
+	}
+	return s.String()
+}
+
+// parseTimeFormat parses --time-format. Formats containing a % are read as
+// strftime, everything else is used as Go layout as is.
+func parseTimeFormat(s string) (timeLayout, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.Contains(s, "%") {
+		return timeLayout{{text: s}}, nil
+	}
+	var (
+		layout  timeLayout
+		literal strings.Builder
+	)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			literal.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, fmt.Errorf("time format %q ends with %%", s)
+		}
+		if s[i] == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+		l, ok := strftimeLayouts[s[i]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported conversion %%%c in time format %q", s[i], s)
+		}
+		if literal.Len() > 0 {
+			layout = append(layout, timeLayoutPart{text: literal.String(), literal: true})
+			literal.Reset()
+		}
+		layout = append(layout, timeLayoutPart{text: l})
+	}
+	if literal.Len() > 0 {
+		layout = append(layout, timeLayoutPart{text: literal.String(), literal: true})
+	}
+	return layout, nil
+}
+
+// timeText formats date and timestamp values with --time-format, or in ISO
+// 8601 without the parts the column type doesn't have.
+func (f *valueFormat) timeText(field pgconn.FieldDescription, t time.Time) string {
+	if f.timeFormat != nil {
+		return f.timeFormat.format(t)
+	}
+	switch field.DataTypeOID {
+	case pgtype.DateOID:
+		return t.Format("2006-01-02")
+	case pgtype.TimestampOID:
+		return t.Format("2006-01-02T15:04:05.999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
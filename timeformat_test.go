@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	ts := time.Date(2024, 3, 9, 7, 5, 1, 0, time.UTC)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"2006-01-02 15:04", "2024-03-09 07:05"},
+		{"%Y-%m-%d %H:%M:%S", "2024-03-09 07:05:01"},
+		{"%d.%m.%y %T %Z", "09.03.24 07:05:01 UTC"},
+		{"%F 100%%", "2024-03-09 100%"},
+		// Literal text is kept even where Go would read a layout field.
+		{"%H:%M Uhr, 1. Quartal, Mon PM", "07:05 Uhr, 1. Quartal, Mon PM"},
+	}
+	for _, tt := range tests {
+		layout, err := parseTimeFormat(tt.format)
+		if err != nil {
+			t.Errorf("parseTimeFormat(%q): %v", tt.format, err)
+			continue
+		}
+		if got := layout.format(ts); got != tt.want {
+			t.Errorf("time format %q = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+	for _, format := range []string{"%Y-%", "%Q"} {
+		if _, err := parseTimeFormat(format); err == nil {
+			t.Errorf("parseTimeFormat(%q) succeeded, want an error", format)
+		}
+	}
+}
+
+func TestTimeText(t *testing.T) {
+	ts := time.Date(2024, 3, 9, 7, 5, 1, 250000000, time.UTC)
+	tests := []struct {
+		format string
+		oid    uint32
+		want   string
+	}{
+		{"", pgtype.DateOID, "2024-03-09"},
+		{"", pgtype.TimestampOID, "2024-03-09T07:05:01.25"},
+		{"", pgtype.TimestamptzOID, "2024-03-09T07:05:01.25Z"},
+		{"%d.%m.%Y %H:%M", pgtype.TimestamptzOID, "09.03.2024 07:05"},
+	}
+	for _, tt := range tests {
+		f, err := newValueFormat(outputArgs{timeFormat: tt.format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.timeText(pgconn.FieldDescription{DataTypeOID: tt.oid}, ts); got != tt.want {
+			t.Errorf("timeText(%q, %d) = %q, want %q", tt.format, tt.oid, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// timeoutExitCode is the exit code when a statement exceeds --timeout.
+const timeoutExitCode = 3
+
+// errStatementTimeout is the cause of the statement deadline, to tell it
+// apart from the other deadlines like --connect-timeout.
+var errStatementTimeout = errors.New("statement exceeded --timeout")
+
+// timeoutGrace is added to the context deadline of a statement, so the
+// server can cancel it through statement_timeout before the connection is
+// dropped.
+const timeoutGrace = time.Second
+
+// statementContext returns the context a statement runs with under
+// --timeout.
+func statementContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout+timeoutGrace, errStatementTimeout)
+}
+
+// statementError marks err as a timeout of --timeout when the statement
+// context ran out or the server canceled the statement for it. The SQLSTATE
+// 57014 of statement_timeout is shared with pg_cancel_backend and cancel
+// requests, and the message depends on lc_messages, so a cancel counts
+// once the statement ran for longer than --timeout.
+func statementError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || timeout <= 0 {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if errors.Is(context.Cause(ctx), errStatementTimeout) || errors.As(err, &pgErr) && pgErr.Code == "57014" && timeoutElapsed(ctx) {
+		return fmt.Errorf("%w: %w", errStatementTimeout, err)
+	}
+	return err
+}
+
+// timeoutElapsed reports whether the --timeout of the statement context
+// has passed, its deadline is timeoutGrace later.
+func timeoutElapsed(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline.Add(-timeoutGrace))
+}
+
+// isTimeout reports whether err is caused by --timeout.
+func isTimeout(err error) bool {
+	return errors.Is(err, errStatementTimeout)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestStatementErrorTimeouts(t *testing.T) {
+	serverTimeout := &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}
+	localized := &pgconn.PgError{Code: "57014", Message: "storniere Anfrage wegen Zeitüberschreitung der Anfrage"}
+	canceled := &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// elapsed is the time the statement ran before failing.
+		elapsed time.Duration
+		err     error
+		want    bool
+	}{
+		{"server statement_timeout", time.Millisecond, 2 * time.Millisecond, serverTimeout, true},
+		{"localized statement_timeout", time.Millisecond, 2 * time.Millisecond, localized, true},
+		{"statement_timeout without --timeout", 0, 0, serverTimeout, false},
+		{"cancel request", time.Second, 0, canceled, false},
+		{"other error", time.Millisecond, 2 * time.Millisecond, errors.New("connection refused"), false},
+		{"connect timeout", time.Second, 0, context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		ctx, cancel := statementContext(context.Background(), tt.timeout)
+		time.Sleep(tt.elapsed)
+		err := statementError(ctx, tt.timeout, tt.err)
+		cancel()
+		if got := isTimeout(err); got != tt.want {
+			t.Errorf("%s: isTimeout(%v) = %v, want %v", tt.name, err, got, tt.want)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: %v doesn't wrap %v", tt.name, err, tt.err)
+		}
+	}
+	if isTimeout(nil) || statementError(context.Background(), time.Second, nil) != nil {
+		t.Error("nil is a timeout")
+	}
+}
+
+func TestStatementErrorDeadline(t *testing.T) {
+	ctx, cancel := statementContext(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if err := statementError(ctx, time.Millisecond, ctx.Err()); !isTimeout(err) {
+		t.Errorf("isTimeout(%v) = false after the statement deadline", err)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// skipTLSVerify turns off the verification of the server certificate for
+// --tls-skip-verify, e.g. for lab clusters with self-signed certificates.
+// The connection stays encrypted, plaintext fallbacks are dropped.
+func skipTLSVerify(config *pgxpool.Config) error {
+	if err := requireTLS(config, "--tls-skip-verify"); err != nil {
+		return err
+	}
+	cc := config.ConnConfig
+	configs := []*tls.Config{cc.TLSConfig}
+	for _, fb := range cc.Fallbacks {
+		configs = append(configs, fb.TLSConfig)
+	}
+	for _, c := range configs {
+		c.InsecureSkipVerify = true
+		c.VerifyPeerCertificate = nil
+		c.VerifyConnection = nil
+	}
+	fmt.Fprintln(os.Stderr, "WARNING: --tls-skip-verify is set, the server certificate is NOT verified and the connection can be intercepted")
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// unalignedWriter mimics psql's unaligned output (-A): values joined by the
+// field separator without padding or escaping, a header line and a row
+// count footer unless tuples only output is requested.
+type unalignedWriter struct {
+	out      *bufio.Writer
+	values   *valueFormat
+	sep      string
+	noHeader bool
+	expanded bool
+	fields   []pgconn.FieldDescription
+	rows     int
+}
+
+func (w *unalignedWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	if w.noHeader || w.expanded {
+		return nil
+	}
+	for i, f := range fields {
+		if i > 0 {
+			w.out.WriteString(w.sep)
+		}
+		w.out.WriteString(f.Name)
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+func (w *unalignedWriter) WriteRow(values []any) error {
+	w.rows++
+	if w.expanded {
+		if w.rows > 1 {
+			w.out.WriteString("\n")
+		}
+		for i, v := range values {
+			w.out.WriteString(w.fields[i].Name)
+			w.out.WriteString(w.sep)
+			w.out.WriteString(w.value(i, v))
+			w.out.WriteString("\n")
+		}
+		return nil
+	}
+	for i, v := range values {
+		if i > 0 {
+			w.out.WriteString(w.sep)
+		}
+		w.out.WriteString(w.value(i, v))
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+func (w *unalignedWriter) value(i int, v any) string {
+	if v == nil {
+		return w.values.nullString("")
+	}
+	return w.values.text(w.fields[i], v)
+}
+
+func (w *unalignedWriter) Close() error {
+	if !w.noHeader && !w.expanded {
+		if w.rows == 1 {
+			w.out.WriteString("(1 row)\n")
+		} else {
+			fmt.Fprintf(w.out, "(%d rows)\n", w.rows)
+		}
+	}
+	return w.out.Flush()
+}
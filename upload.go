@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// uploadBufferSize is how much output is collected before it is passed
+// on to an upload.
+const uploadBufferSize = 64 << 10
+
+// uploaders create the upload to an object in a bucket, by URL scheme.
+var uploaders = map[string]func(bucket, key string) (func(context.Context, io.Reader) error, error){
+	"s3": s3Upload,
+	"gs": gcsUpload,
+	"az": azureUpload,
+}
+
+// remoteOutput returns the upload for an object storage URL given as
+// --output, or nil for local files.
+func remoteOutput(output string) (io.WriteCloser, error) {
+	u, err := url.Parse(output)
+	if err != nil || u.Host == "" || uploaders[u.Scheme] == nil {
+		return nil, nil
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("no object key in %s", output)
+	}
+	upload, err := uploaders[u.Scheme](u.Host, key)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadOutput{upload: upload}, nil
+}
+
+// uploadOutput streams the output to an upload, which starts with the
+// first write like the output file is only created then.
+type uploadOutput struct {
+	upload func(context.Context, io.Reader) error
+	pw     *io.PipeWriter
+	w      *bufio.Writer
+	done   chan error
+}
+
+func (o *uploadOutput) Write(p []byte) (int, error) {
+	if o.pw == nil {
+		pr, pw := io.Pipe()
+		o.pw = pw
+		o.w = bufio.NewWriterSize(pw, uploadBufferSize)
+		o.done = make(chan error, 1)
+		go func() {
+			err := o.upload(context.Background(), pr)
+			pr.CloseWithError(err)
+			o.done <- err
+		}()
+	}
+	return o.w.Write(p)
+}
+
+func (o *uploadOutput) Close() error {
+	if o.pw == nil {
+		return nil
+	}
+	if err := o.w.Flush(); err != nil {
+		o.abort(err)
+		return err
+	}
+	o.pw.Close()
+	return <-o.done
+}
+
+// abort cancels the upload, so a failed export leaves no truncated object.
+func (o *uploadOutput) abort(err error) {
+	if o.pw == nil {
+		return
+	}
+	o.pw.CloseWithError(err)
+	<-o.done
+}
+
+// gzipOutput compresses the output for --output files ending in .gz.
+type gzipOutput struct {
+	next io.WriteCloser
+	gz   *gzip.Writer
+}
+
+func (o *gzipOutput) Write(p []byte) (int, error) {
+	if o.gz == nil {
+		o.gz = gzip.NewWriter(o.next)
+	}
+	return o.gz.Write(p)
+}
+
+func (o *gzipOutput) Close() error {
+	if o.gz != nil {
+		if err := o.gz.Close(); err != nil {
+			o.next.Close()
+			return err
+		}
+	}
+	return o.next.Close()
+}
+
+func (o *gzipOutput) abort(err error) {
+	closeOutput(o.next, err)
+}
+
+// closeOutput closes the output after the statements ran. When they
+// failed, uploads are aborted instead of completed.
+func closeOutput(out io.WriteCloser, err error) error {
+	if a, ok := out.(interface{ abort(error) }); ok && err != nil {
+		a.abort(err)
+		return nil
+	}
+	return out.Close()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var typeMap = pgtype.NewMap()
+
+// typeName returns the name of a built-in type, or the OID for types pgx
+// doesn't know about.
+func typeName(oid uint32) string {
+	if t, ok := typeMap.TypeForOID(oid); ok {
+		return t.Name
+	}
+	return strconv.FormatUint(uint64(oid), 10)
+}
+
+// valueFormat holds the options that control how scanned values are
+// rendered by the output formats.
+type valueFormat struct {
+	null        string
+	nullSet     bool
+	color       bool
+	prettyJSON  bool
+	bytea       string
+	timeFormat  timeLayout
+	numeric     numericFormat
+	arrayFormat string
+}
+
+func newValueFormat(outArgs outputArgs) (*valueFormat, error) {
+	switch outArgs.bytea {
+	case "", "hex", "base64", "escape", "omit":
+	default:
+		return nil, fmt.Errorf("unknown bytea format %q", outArgs.bytea)
+	}
+	switch outArgs.arrayFormat {
+	case "", "pg", "json":
+	default:
+		return nil, fmt.Errorf("unknown array format %q", outArgs.arrayFormat)
+	}
+	timeFormat, err := parseTimeFormat(outArgs.timeFormat)
+	if err != nil {
+		return nil, err
+	}
+	numeric, err := parseNumericFormat(outArgs.numericFormat, outArgs.numericLocale)
+	if err != nil {
+		return nil, err
+	}
+	return &valueFormat{
+		null:        outArgs.null,
+		nullSet:     outArgs.nullSet,
+		prettyJSON:  outArgs.prettyJSON,
+		bytea:       outArgs.bytea,
+		timeFormat:  timeFormat,
+		numeric:     numeric,
+		arrayFormat: outArgs.arrayFormat,
+	}, nil
+}
+
+// byteaText encodes binary values according to --bytea. hex and escape
+// match the bytea_output settings of the server.
+func (f *valueFormat) byteaText(b []byte) string {
+	switch f.bytea {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b)
+	case "escape":
+		var s strings.Builder
+		for _, c := range b {
+			switch {
+			case c == '\\':
+				s.WriteString(`\\`)
+			case c < 0x20 || c > 0x7e:
+				fmt.Fprintf(&s, `\%03o`, c)
+			default:
+				s.WriteByte(c)
+			}
+		}
+		return s.String()
+	case "omit":
+		return fmt.Sprintf("(%d bytes)", len(b))
+	default:
+		return `\x` + hex.EncodeToString(b)
+	}
+}
+
+// nullString returns the --null representation, or def for formats with
+// their own convention when it wasn't given.
+func (f *valueFormat) nullString(def string) string {
+	if f.nullSet {
+		return f.null
+	}
+	return def
+}
+
+// display renders a scanned value as text for the table output. NULL is
+// styled when colors are enabled so it can't be confused with text.
+func (f *valueFormat) display(field pgconn.FieldDescription, v any) string {
+	if v == nil {
+		null := f.nullString("null")
+		if f.color {
+			return text.Faint.Sprint(null)
+		}
+		return null
+	}
+	switch val := v.(type) {
+	case []byte:
+		return f.byteaText(val)
+	case time.Time:
+		return f.timeText(field, val)
+	case pgtype.Numeric:
+		return f.numeric.format(val)
+	case []any:
+		return f.arrayText(field, val)
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		if f.prettyJSON {
+			return prettyJSON(raw, f.color)
+		}
+		return compactJSON(raw)
+	}
+	return f.text(field, v)
+}
+
+// json converts a scanned value into something encoding/json renders with
+// its natural JSON type.
+func (f *valueFormat) json(field pgconn.FieldDescription, v any) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return f.byteaText(val)
+	case []any:
+		elem, _ := elementField(field)
+		arr := make([]any, len(val))
+		for i, e := range val {
+			arr[i] = f.json(elem, e)
+		}
+		return arr
+	case time.Time:
+		// Dates and explicit formats become strings, encoding/json would
+		// add a midnight UTC time to dates.
+		if f.timeFormat != nil || field.DataTypeOID == pgtype.DateOID {
+			return f.timeText(field, val)
+		}
+	case [16]uint8:
+		if field.DataTypeOID == pgtype.UUIDOID {
+			uuidVal, err := uuid.FromBytes(val[:])
+			if err == nil {
+				return uuidVal.String()
+			}
+		}
+		return fmt.Sprintf("%x", val)
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return fmt.Sprint(val)
+		}
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Sprint(val)
+		}
+	case json.Marshaler, encoding.TextMarshaler:
+		return v
+	case driver.Valuer:
+		if dv, err := val.Value(); err == nil {
+			return dv
+		}
+	}
+	return v
+}
+
+// text renders a non-null scanned value as plain text for machine readable
+// output formats.
+func (f *valueFormat) text(field pgconn.FieldDescription, v any) string {
+	switch val := v.(type) {
+	case pgtype.Numeric:
+		return f.numeric.format(val)
+	case []any:
+		return f.arrayText(field, val)
+	}
+	switch val := f.json(field, v).(type) {
+	case string:
+		return val
+	case json.RawMessage:
+		return compactJSON(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case int16:
+		return strconv.FormatInt(int64(val), 10)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Time:
+		return f.timeText(field, val)
+	case encoding.TextMarshaler:
+		if b, err := val.MarshalText(); err == nil {
+			return string(b)
+		}
+	case json.Marshaler:
+		if b, err := val.MarshalJSON(); err == nil {
+			return strings.Trim(string(b), `"`)
+		}
+	case map[string]any:
+		if b, err := json.Marshal(val); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
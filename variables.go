@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} with the environment variable for --envsubst.
+// Only the braced form is expanded, $1 and $$ have a meaning in SQL.
+func expandEnv(sql string) (string, error) {
+	var err error
+	expanded := envPattern.ReplaceAllStringFunc(sql, func(ref string) string {
+		name := envPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// parseVariables parses --set name=value flags.
+func parseVariables(flags []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, f := range flags {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok || trim(name) == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected name=value", f)
+		}
+		vars[trim(name)] = value
+	}
+	return vars, nil
+}
+
+// unquoteMetaArg strips the single quotes of a quoted meta-command
+// argument like 'two words'.
+func unquoteMetaArg(s string) string {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// substituteVariables replaces :name with the value of the variable,
+// :'name' with it quoted as literal and :"name" quoted as identifier.
+// Unknown variables are left alone.
+func substituteVariables(sql string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return sql
+	}
+	segments := scanSQL(sql)
+	var out strings.Builder
+	for i, seg := range segments {
+		if seg.kind != codeSegment {
+			out.WriteString(seg.text)
+			continue
+		}
+		s := seg.text
+		for j := 0; j < len(s); j++ {
+			if j == len(s)-1 && s[j] == ':' && (j == 0 || s[j-1] != ':') && i+1 < len(segments) {
+				if quoted, ok := quotedVariable(segments[i+1].text, vars); ok {
+					out.WriteString(quoted)
+					segments[i+1].text = ""
+					continue
+				}
+			}
+			name := namedRef(s, j)
+			value, ok := vars[name]
+			if name == "" || !ok {
+				out.WriteByte(s[j])
+				continue
+			}
+			out.WriteString(value)
+			j += len(name)
+		}
+	}
+	return out.String()
+}
+
+// quotedVariable returns the quoted value for the 'name' or "name" that
+// follows a colon.
+func quotedVariable(text string, vars map[string]string) (string, bool) {
+	if len(text) < 3 || text[0] != text[len(text)-1] || text[0] != '\'' && text[0] != '"' {
+		return "", false
+	}
+	value, ok := vars[text[1:len(text)-1]]
+	if !ok {
+		return "", false
+	}
+	if text[0] == '"' {
+		return pgx.Identifier{value}.Sanitize(), true
+	}
+	return quoteLiteral(value), true
+}
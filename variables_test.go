@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVariables(t *testing.T) {
+	got, err := parseVariables([]string{"a=1", " b =x=y", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "1", "b": "x=y", "empty": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVariables = %v, want %v", got, want)
+	}
+	for _, flag := range []string{"novalue", "=1"} {
+		if _, err := parseVariables([]string{flag}); err == nil {
+			t.Errorf("parseVariables(%q) succeeded, want an error", flag)
+		}
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	vars := map[string]string{"id": "42", "name": "O'Brien", "tbl": `my "table"`}
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT :id", "SELECT 42"},
+		{"SELECT :'name'", "SELECT 'O''Brien'"},
+		{`SELECT * FROM :"tbl"`, `SELECT * FROM "my ""table"""`},
+		{"SELECT :unknown, :'unknown'", "SELECT :unknown, :'unknown'"},
+		{"SELECT ':id', \":id\" -- :id", "SELECT ':id', \":id\" -- :id"},
+		{"SELECT 1::int, a[1:2]", "SELECT 1::int, a[1:2]"},
+		{"SELECT :id::text", "SELECT 42::text"},
+	}
+	for _, tt := range tests {
+		if got := substituteVariables(tt.sql, vars); got != tt.want {
+			t.Errorf("substituteVariables(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestUnquoteMetaArg(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"plain", "plain"},
+		{"'two words'", "two words"},
+		{"'it''s'", "it's"},
+		{"'", "'"},
+	}
+	for _, tt := range tests {
+		if got := unquoteMetaArg(tt.arg); got != tt.want {
+			t.Errorf("unquoteMetaArg(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPattern matches the leading version number of server_version,
+// e.g. 16.4 of "16.4 (Debian 16.4-1.pgdg120+1)" or 17 of "17beta1".
+var versionPattern = regexp.MustCompile(`^\d+(\.\d+)*`)
+
+// versionConstraint is a comparison like >=14 of --require-version.
+type versionConstraint struct {
+	op      string
+	version []int
+}
+
+// parseVersionConstraints parses --require-version, one or more
+// comparisons separated by commas like ">=14,<17". A version without
+// operator must match exactly, 16 also matches 16.4.
+func parseVersionConstraints(s string) ([]versionConstraint, error) {
+	var constraints []versionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = trim(part)
+		op := ""
+		for _, o := range []string{">=", "<=", "==", ">", "<", "="} {
+			if strings.HasPrefix(part, o) {
+				op = o
+				break
+			}
+		}
+		v := trim(strings.TrimPrefix(part, op))
+		version, ok := parseVersion(v)
+		// Unlike server_version, nothing may follow the number.
+		if !ok || versionPattern.FindString(v) != v {
+			return nil, fmt.Errorf("invalid --require-version %q, expected a comparison like >=14", s)
+		}
+		constraints = append(constraints, versionConstraint{op: op, version: version})
+	}
+	return constraints, nil
+}
+
+// parseVersion returns the numbers of a version like 9.6 or 16.4.
+func parseVersion(s string) ([]int, bool) {
+	m := versionPattern.FindString(s)
+	if m == "" {
+		return nil, false
+	}
+	var version []int
+	for _, n := range strings.Split(m, ".") {
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, false
+		}
+		version = append(version, i)
+	}
+	return version, true
+}
+
+// checkServerVersion fails with a message naming both versions unless the
+// server_version satisfies all constraints.
+func checkServerVersion(constraints []versionConstraint, serverVersion, required string) error {
+	version, ok := parseVersion(serverVersion)
+	if !ok {
+		return fmt.Errorf("can't parse server version %q", serverVersion)
+	}
+	for _, c := range constraints {
+		if !c.satisfied(version) {
+			return fmt.Errorf("server version %s doesn't satisfy --require-version %s", serverVersion, required)
+		}
+	}
+	return nil
+}
+
+func (c versionConstraint) satisfied(version []int) bool {
+	// Only the parts given in the constraint are compared.
+	cmp := 0
+	for i, n := range c.version {
+		v := 0
+		if i < len(version) {
+			v = version[i]
+		}
+		if v != n {
+			cmp = v - n
+			break
+		}
+	}
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
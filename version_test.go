@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestCheckServerVersion(t *testing.T) {
+	tests := []struct {
+		required string
+		server   string
+		want     bool
+	}{
+		{">=14", "14.5", true},
+		{">=14", "13.9 (Debian 13.9-1)", false},
+		{"<16.4", "16.3", true},
+		{"<16.4", "16.4", false},
+		// Only the major version is compared, 14.5 is no later major.
+		{">14", "14.5", false},
+		{">14", "15.0", true},
+		{"16", "16.4", true},
+		{">=14,<17", "17beta1", false},
+	}
+	for _, tt := range tests {
+		constraints, err := parseVersionConstraints(tt.required)
+		if err != nil {
+			t.Errorf("parseVersionConstraints(%q): %v", tt.required, err)
+			continue
+		}
+		if got := checkServerVersion(constraints, tt.server, tt.required) == nil; got != tt.want {
+			t.Errorf("server %q satisfies %q = %v, want %v", tt.server, tt.required, got, tt.want)
+		}
+	}
+	for _, required := range []string{">=14abc", ">=14.x", "", ">=", "~14", ">=14,"} {
+		if _, err := parseVersionConstraints(required); err == nil {
+			t.Errorf("parseVersionConstraints(%q) succeeded, want an error", required)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// waitInterval is the pause between the readiness checks of --wait.
+const waitInterval = time.Second
+
+// errNotReady is returned by checkReady when the --wait-query says no.
+var errNotReady = errors.New("readiness query returned false")
+
+// errNoRows is returned by checkReady when the --wait-query returns no
+// rows, which also means the database isn't ready.
+var errNoRows = errors.New("readiness query returned no rows")
+
+// waitReady blocks until the database accepts connections and the
+// --wait-query, if any, reports it ready, or --wait-timeout passes. Each
+// check uses a new connection. Errors that won't go away by waiting, like
+// a wrong password or a missing database, fail right away. The password
+// of --prompt-password is asked for once and kept in connArgs for the run.
+func waitReady(ctx context.Context, connArgs *connArgs) error {
+	config, err := poolConfig(ctx, *connArgs)
+	if err != nil {
+		return err
+	}
+	if connArgs.promptPassword {
+		password, err := readSecret(fmt.Sprintf("Password for user %s: ", config.ConnConfig.User))
+		if err != nil {
+			return err
+		}
+		config.ConnConfig.Password = password
+		connArgs.password, connArgs.promptPassword = password, false
+	}
+	if connArgs.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, connArgs.waitTimeout)
+		defer cancel()
+	}
+	for {
+		err := checkReady(ctx, config, connArgs.waitQuery)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errNotReady) && !errors.Is(err, errNoRows) && !isTransient(err) {
+			return err
+		}
+		select {
+		case <-time.After(waitInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready within %s (%w): %w", connArgs.waitTimeout, ctx.Err(), err)
+		}
+	}
+}
+
+// checkReady connects and runs the readiness query. The database is ready
+// when it returns a row whose first column isn't false, so queries like
+// SELECT NOT pg_is_in_recovery() can be used.
+func checkReady(ctx context.Context, config *pgxpool.Config, query string) error {
+	pool, err := pgxpool.NewWithConfig(ctx, config.Copy())
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	if query == "" {
+		return pool.Ping(ctx)
+	}
+	rows, err := pool.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return errNoRows
+	}
+	values, err := rows.Values()
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 && values[0] == false {
+		return errNotReady
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	xlsxSheet       = "Result"
+	xlsxMaxColWidth = 60
+)
+
+// xlsxWriter writes the result set into a single sheet of an Excel
+// workbook. Numbers, booleans and timestamps are stored as typed cells,
+// everything else as text.
+type xlsxWriter struct {
+	out    io.Writer
+	values *valueFormat
+	f      *excelize.File
+	fields []pgconn.FieldDescription
+	widths []int
+	row    int
+
+	dateStyle      int
+	timestampStyle int
+}
+
+func (w *xlsxWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	w.widths = make([]int, len(fields))
+	w.f = excelize.NewFile()
+	if err := w.f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return err
+	}
+
+	header := make([]any, len(fields))
+	for i, f := range fields {
+		header[i] = f.Name
+		w.measure(i, f.Name)
+	}
+	if err := w.f.SetSheetRow(xlsxSheet, "A1", &header); err != nil {
+		return err
+	}
+	bold, err := w.f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	if err := w.f.SetRowStyle(xlsxSheet, 1, 1, bold); err != nil {
+		return err
+	}
+	dateFmt, timestampFmt := "yyyy-mm-dd", "yyyy-mm-dd hh:mm:ss"
+	if w.dateStyle, err = w.f.NewStyle(&excelize.Style{CustomNumFmt: &dateFmt}); err != nil {
+		return err
+	}
+	if w.timestampStyle, err = w.f.NewStyle(&excelize.Style{CustomNumFmt: &timestampFmt}); err != nil {
+		return err
+	}
+	w.row = 1
+	return w.f.SetPanes(xlsxSheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+func (w *xlsxWriter) WriteRow(values []any) error {
+	w.row++
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = w.cellValue(i, v)
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	if err := w.f.SetSheetRow(xlsxSheet, cell, &row); err != nil {
+		return err
+	}
+
+	for i, v := range row {
+		if _, ok := v.(time.Time); !ok {
+			continue
+		}
+		style := w.timestampStyle
+		if w.fields[i].DataTypeOID == pgtype.DateOID {
+			style = w.dateStyle
+		}
+		cell, err := excelize.CoordinatesToCellName(i+1, w.row)
+		if err != nil {
+			return err
+		}
+		if err := w.f.SetCellStyle(xlsxSheet, cell, cell, style); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *xlsxWriter) cellValue(i int, v any) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case int16, int32, int64, float32, float64, bool:
+		w.measure(i, w.values.text(w.fields[i], v))
+		return val
+	case time.Time:
+		w.measure(i, "2006-01-02 15:04:05")
+		return val
+	case pgtype.Numeric:
+		if f, err := val.Float64Value(); err == nil && f.Valid {
+			w.measure(i, w.values.text(w.fields[i], v))
+			return f.Float64
+		}
+	}
+	s := w.values.text(w.fields[i], v)
+	w.measure(i, s)
+	return s
+}
+
+func (w *xlsxWriter) measure(i int, s string) {
+	if n := utf8.RuneCountInString(s); n > w.widths[i] {
+		w.widths[i] = min(n, xlsxMaxColWidth)
+	}
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.f.Close()
+	for i, width := range w.widths {
+		col, err := excelize.ColumnNumberToName(i + 1)
+		if err != nil {
+			return err
+		}
+		if err := w.f.SetColWidth(xlsxSheet, col, col, float64(width+2)); err != nil {
+			return err
+		}
+	}
+	_, err := w.f.WriteTo(w.out)
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// xmlWriter emits a <result> document with one <row> element per row. Each
+// value is a <column> element carrying the column name and type as
+// attributes, so column names don't have to be valid XML names.
+type xmlWriter struct {
+	out    *bufio.Writer
+	values *valueFormat
+	fields []pgconn.FieldDescription
+	attrs  []string
+}
+
+func (w *xmlWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	w.attrs = make([]string, len(fields))
+	for i, f := range fields {
+		w.attrs[i] = ` name="` + xmlEscape(f.Name) + `" type="` + xmlEscape(typeName(f.DataTypeOID)) + `"`
+	}
+	_, err := w.out.WriteString(xml.Header + "<result>\n")
+	return err
+}
+
+func (w *xmlWriter) WriteRow(values []any) error {
+	w.out.WriteString("  <row>\n")
+	for i, v := range values {
+		w.out.WriteString("    <column")
+		w.out.WriteString(w.attrs[i])
+		if v == nil {
+			w.out.WriteString(` null="true"/>` + "\n")
+			continue
+		}
+		w.out.WriteString(">")
+		if err := xml.EscapeText(w.out, []byte(w.values.text(w.fields[i], v))); err != nil {
+			return err
+		}
+		w.out.WriteString("</column>\n")
+	}
+	_, err := w.out.WriteString("  </row>\n")
+	return err
+}
+
+func (w *xmlWriter) Close() error {
+	w.out.WriteString("</result>\n")
+	return w.out.Flush()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestXMLWriter(t *testing.T) {
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<result>
+  <row>
+    <column name="id" type="int8">1</column>
+    <column name="name" type="text">a&#x9;b&#xA;&lt;c&gt;</column>
+  </row>
+  <row>
+    <column name="id" type="int8" null="true"/>
+    <column name="name" type="text"></column>
+  </row>
+</result>
+`
+	if got := render(t, outputArgs{format: "xml"}, testFields, []any{int64(1), "a\tb\n<c>"}, []any{nil, ""}); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestXMLWriterEscapesColumnNames(t *testing.T) {
+	fields := []pgconn.FieldDescription{{Name: `a<"b">`, DataTypeOID: pgtype.TextOID}}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<result>
+  <row>
+    <column name="a&lt;&#34;b&#34;&gt;" type="text">x&amp;y</column>
+  </row>
+</result>
+`
+	if got := render(t, outputArgs{format: "xml"}, fields, []any{"x&y"}); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
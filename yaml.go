@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlWriter emits the result set as a single YAML sequence with one
+// mapping per row. Rows are encoded one at a time so nothing is buffered.
+type yamlWriter struct {
+	out    *bufio.Writer
+	values *valueFormat
+	fields []pgconn.FieldDescription
+	rows   int
+}
+
+func (w *yamlWriter) WriteHeader(fields []pgconn.FieldDescription) error {
+	w.fields = fields
+	return nil
+}
+
+func (w *yamlWriter) WriteRow(values []any) error {
+	row := &yaml.Node{Kind: yaml.MappingNode}
+	for i, v := range values {
+		val, err := w.yamlValue(w.fields[i], v)
+		if err != nil {
+			return err
+		}
+		row.Content = append(row.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: w.fields[i].Name}, val)
+	}
+
+	enc := yaml.NewEncoder(w.out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{row}}); err != nil {
+		return err
+	}
+	w.rows++
+	return enc.Close()
+}
+
+func (w *yamlWriter) yamlValue(field pgconn.FieldDescription, v any) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if b, ok := v.([]byte); ok && w.values.bytea == "base64" {
+		node.Kind = yaml.ScalarNode
+		node.Tag = "!!binary"
+		node.Value = base64.StdEncoding.EncodeToString(b)
+		return node, nil
+	}
+	if arr, ok := v.([]any); ok {
+		elem, _ := elementField(field)
+		node.Kind = yaml.SequenceNode
+		for _, e := range arr {
+			n, err := w.yamlValue(elem, e)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
+		}
+		return node, nil
+	}
+	switch val := w.values.json(field, v).(type) {
+	case time.Time:
+		return node, node.Encode(val)
+	case json.Marshaler:
+		// JSON is valid YAML, so reuse the JSON encoding of types like
+		// pgtype.Numeric instead of dumping their struct fields.
+		b, err := val.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, node); err != nil {
+			return nil, err
+		}
+		blockStyle(node)
+		return node.Content[0], nil
+	default:
+		return node, node.Encode(val)
+	}
+}
+
+// blockStyle drops the flow style and quoting taken over from JSON, so
+// decoded JSON is rendered like the rest of the document.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, c := range node.Content {
+		blockStyle(c)
+	}
+}
+
+func (w *yamlWriter) Close() error {
+	if w.rows == 0 {
+		w.out.WriteString("[]\n")
+	}
+	return w.out.Flush()
+}
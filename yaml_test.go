@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestYAMLWriter(t *testing.T) {
+	tests := []struct {
+		rows [][]any
+		want string
+	}{
+		{nil, "[]\n"},
+		{[][]any{{int64(1), "a\tb\\c\nd"}}, "- id: 1\n  name: |-\n    a\tb\\c\n    d\n"},
+		// Strings YAML would read as another type stay quoted.
+		{[][]any{{nil, ""}, {int64(3), "yes"}}, "- id: null\n  name: \"\"\n- id: 3\n  name: \"yes\"\n"},
+	}
+	for _, tt := range tests {
+		if got := render(t, outputArgs{format: "yaml"}, testFields, tt.rows...); got != tt.want {
+			t.Errorf("yaml of %v = %q, want %q", tt.rows, got, tt.want)
+		}
+	}
+
+	// Column names YAML would read as another type stay strings.
+	fields := []pgconn.FieldDescription{{Name: "null", DataTypeOID: pgtype.TextOID}, {Name: "1", DataTypeOID: pgtype.TextOID}}
+	if got, want := render(t, outputArgs{format: "yaml"}, fields, []any{"x", "y"}), "- \"null\": x\n  \"1\": \"y\"\n"; got != want {
+		t.Errorf("yaml with column names null and 1 = %q, want %q", got, want)
+	}
+}